@@ -0,0 +1,246 @@
+package agents
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/peterje/superposition/internal/db"
+)
+
+// Agent describes a CLI coding agent that sessions can be started with.
+type Agent struct {
+	Name   string `json:"name"`
+	Binary string `json:"binary"`
+	// Args are passed to Binary on every launch.
+	Args []string          `json:"args,omitempty"`
+	Env  map[string]string `json:"env,omitempty"`
+	// AuthCheckArgs, when set, are passed to Binary to check whether the
+	// agent is logged in; exit status 0 means authed. If empty the agent is
+	// assumed to be authed whenever it is installed.
+	AuthCheckArgs []string `json:"auth_check_args,omitempty"`
+	// VersionArgs, when set, are passed to Binary to print its version.
+	VersionArgs []string `json:"version_args,omitempty"`
+	// ResumeArgs are appended to Args when a stopped session is restarted
+	// so the agent picks up its previous conversation.
+	ResumeArgs []string `json:"resume_args,omitempty"`
+	// Prompt says how a session's initial prompt is handed to the agent.
+	// Without it the prompt is typed into the terminal.
+	Prompt *PromptTemplate `json:"prompt,omitempty"`
+}
+
+// Ways of passing an initial prompt to an agent.
+const (
+	PromptArgv  = "argv"  // as command-line arguments
+	PromptStdin = "stdin" // piped to the agent's stdin, which then closes
+	PromptPTY   = "pty"   // typed into the terminal once the agent is up
+)
+
+// PromptTemplate configures how an agent receives its initial prompt.
+type PromptTemplate struct {
+	Mode string `json:"mode"` // argv, stdin or pty (the default)
+	// Args are appended to the launch command in argv mode, with "{prompt}"
+	// replaced by the prompt. Defaults to ["{prompt}"].
+	Args []string `json:"args,omitempty"`
+	// DelayMS is how long pty mode waits for the agent to draw its UI
+	// before typing. Defaults to 3000.
+	DelayMS int `json:"delay_ms,omitempty"`
+}
+
+// PromptTemplate returns the agent's prompt template with defaults filled in.
+func (a Agent) PromptTemplate() PromptTemplate {
+	var t PromptTemplate
+	if a.Prompt != nil {
+		t = *a.Prompt
+	}
+	if t.Mode == "" {
+		t.Mode = PromptPTY
+	}
+	if t.Mode == PromptArgv && len(t.Args) == 0 {
+		t.Args = []string{"{prompt}"}
+	}
+	if t.DelayMS <= 0 {
+		t.DelayMS = 3000
+	}
+	return t
+}
+
+// Command returns the argv used to launch the agent.
+func (a Agent) Command() []string {
+	return append([]string{a.Binary}, a.Args...)
+}
+
+// ResumeCommand returns the argv used to relaunch the agent in a session it
+// previously ran in. It is Command if the agent has no ResumeArgs.
+func (a Agent) ResumeCommand() []string {
+	return append(a.Command(), a.ResumeArgs...)
+}
+
+// PromptCommand returns the argv used to launch the agent with an initial
+// prompt. It is Command unless the agent takes its prompt as arguments.
+func (a Agent) PromptCommand(prompt string) []string {
+	t := a.PromptTemplate()
+	argv := a.Command()
+	if t.Mode != PromptArgv {
+		return argv
+	}
+	for _, arg := range t.Args {
+		argv = append(argv, strings.ReplaceAll(arg, "{prompt}", prompt))
+	}
+	return argv
+}
+
+// Environ returns the agent's extra environment as KEY=VALUE pairs.
+func (a Agent) Environ() []string {
+	env := make([]string, 0, len(a.Env))
+	for k, v := range a.Env {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// builtins are always registered. Entries from the config file or settings
+// with the same name replace them.
+var builtins = []Agent{
+	{Name: "claude", Binary: "claude", VersionArgs: []string{"--version"}, ResumeArgs: []string{"--continue"},
+		Prompt: &PromptTemplate{Mode: PromptArgv}},
+	{Name: "codex", Binary: "codex", VersionArgs: []string{"--version"}, ResumeArgs: []string{"resume", "--last"},
+		Prompt: &PromptTemplate{Mode: PromptArgv}},
+	{Name: "gemini", Binary: "gemini", VersionArgs: []string{"--version"},
+		Prompt: &PromptTemplate{Mode: PromptArgv, Args: []string{"--prompt-interactive", "{prompt}"}}},
+}
+
+// settingsKey holds a JSON array of Agent definitions.
+const settingsKey = "agents"
+
+// ConfigPath returns the path to the optional agents config file, a JSON
+// array of Agent definitions.
+func ConfigPath() (string, error) {
+	dir, err := db.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "agents.json"), nil
+}
+
+// Load returns every registered agent in registration order. Built-in agents
+// come first, then the config file, then the "agents" setting; later
+// definitions replace earlier ones with the same name. A legacy
+// "cli_command.<name>" setting still overrides an agent's binary and args.
+func Load(database *sql.DB) []Agent {
+	registry := append([]Agent(nil), builtins...)
+
+	if path, err := ConfigPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			registry = merge(registry, parse(data, path))
+		}
+	}
+
+	if database != nil {
+		var val string
+		if err := database.QueryRow(`SELECT value FROM settings WHERE key = ?`, settingsKey).Scan(&val); err == nil && val != "" {
+			registry = merge(registry, parse([]byte(val), "settings."+settingsKey))
+		}
+	}
+
+	for i := range registry {
+		if override := CommandOverride(database, registry[i].Name); override != "" {
+			fields := strings.Fields(override)
+			registry[i].Binary = fields[0]
+			registry[i].Args = fields[1:]
+		}
+	}
+	return registry
+}
+
+// Lookup returns the registered agent with the given name.
+func Lookup(database *sql.DB, name string) (Agent, bool) {
+	for _, a := range Load(database) {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Agent{}, false
+}
+
+// IsSetting reports whether a setting configures agents: the "agents"
+// registry or a legacy "cli_command.<name>" override.
+func IsSetting(key string) bool {
+	return key == settingsKey || strings.HasPrefix(key, "cli_command.")
+}
+
+// CommandOverride returns the legacy "cli_command.<name>" setting, if any.
+func CommandOverride(database *sql.DB, name string) string {
+	if database == nil {
+		return ""
+	}
+	var val string
+	err := database.QueryRow(`SELECT value FROM settings WHERE key = ?`, "cli_command."+name).Scan(&val)
+	if err != nil || strings.TrimSpace(val) == "" {
+		return ""
+	}
+	return val
+}
+
+// parse decodes a JSON array of agents, dropping invalid entries.
+func parse(data []byte, source string) []Agent {
+	var defs []Agent
+	if err := json.Unmarshal(data, &defs); err != nil {
+		log.Printf("Ignoring agent definitions from %s: %v", source, err)
+		return nil
+	}
+	valid := defs[:0]
+	for _, a := range defs {
+		if err := validate(a); err != nil {
+			log.Printf("Ignoring agent definition from %s: %v", source, err)
+			continue
+		}
+		valid = append(valid, a)
+	}
+	return valid
+}
+
+func validate(a Agent) error {
+	if a.Name == "" {
+		return fmt.Errorf("agent name is required")
+	}
+	if strings.ContainsAny(a.Name, " \t/") {
+		return fmt.Errorf("agent %q: name must not contain spaces or slashes", a.Name)
+	}
+	if strings.TrimSpace(a.Binary) == "" {
+		return fmt.Errorf("agent %q: binary is required", a.Name)
+	}
+	if a.Prompt != nil {
+		switch a.Prompt.Mode {
+		case "", PromptArgv, PromptStdin, PromptPTY:
+		default:
+			return fmt.Errorf("agent %q: prompt mode must be argv, stdin or pty", a.Name)
+		}
+	}
+	return nil
+}
+
+// merge overlays defs onto registry, replacing agents with matching names
+// and appending new ones.
+func merge(registry, defs []Agent) []Agent {
+	for _, a := range defs {
+		replaced := false
+		for i := range registry {
+			if registry[i].Name == a.Name {
+				registry[i] = a
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			registry = append(registry, a)
+		}
+	}
+	return registry
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peterje/superposition/internal/db"
+	"github.com/peterje/superposition/internal/git"
+)
+
+// localURL is where agent sessions reach this server, set by SetLocalURL.
+var localURL string
+
+// SetLocalURL sets the base URL, e.g. http://127.0.0.1:8800, that the askpass
+// helper of agent sessions fetches credentials from.
+func SetLocalURL(u string) {
+	localURL = strings.TrimRight(u, "/")
+}
+
+var (
+	askpassSecretOnce sync.Once
+	askpassSecret     []byte
+	askpassSecretErr  error
+)
+
+// loadAskpassSecret returns the key that session tokens are signed with,
+// creating it on first use. It is kept in the data dir rather than memory so
+// sessions outliving a server restart keep working.
+func loadAskpassSecret() ([]byte, error) {
+	askpassSecretOnce.Do(func() {
+		dataDir, err := db.DataDir()
+		if err != nil {
+			askpassSecretErr = err
+			return
+		}
+		path := filepath.Join(dataDir, "askpass.key")
+		secret, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			secret = make([]byte, 32)
+			if _, err = rand.Read(secret); err == nil {
+				err = os.WriteFile(path, secret, 0600)
+			}
+		}
+		if err != nil {
+			askpassSecretErr = fmt.Errorf("askpass key: %w", err)
+			return
+		}
+		askpassSecret = secret
+	})
+	return askpassSecret, askpassSecretErr
+}
+
+// sessionTokenTTL is how long the credentials token of an agent session is
+// valid. Restarting the session issues a new one.
+const sessionTokenTTL = 12 * time.Hour
+
+// sessionToken returns the token a session's askpass helper presents to fetch
+// its repository's credentials, "<expiry>.<mac>". It only unlocks that
+// session's, and only while the session is running and until it expires.
+func sessionToken(sessionID string, expires time.Time) (string, error) {
+	secret, err := loadAskpassSecret()
+	if err != nil {
+		return "", err
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + sessionTokenMAC(secret, sessionID, exp), nil
+}
+
+func sessionTokenMAC(secret []byte, sessionID, exp string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(sessionID + "." + exp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkSessionToken reports whether token was issued for the session and
+// hasn't expired.
+func checkSessionToken(sessionID, token string) (bool, error) {
+	secret, err := loadAskpassSecret()
+	if err != nil {
+		return false, err
+	}
+	exp, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return false, nil
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false, nil
+	}
+	return hmac.Equal([]byte(mac), []byte(sessionTokenMAC(secret, sessionID, exp))), nil
+}
+
+// sessionCredentials returns the credentials for an agent session of a
+// repository. Sessions never get the token itself: they get a short-lived
+// token to fetch the current one from this server whenever git asks, which
+// also keeps them working past the expiry of GitHub App tokens.
+func sessionCredentials(db *sql.DB, sessionID string, repoID int64, host string) git.Credentials {
+	creds := repoCredentials(db, repoID, host)
+	if creds.Token == "" {
+		return creds
+	}
+	creds.Username, creds.Token = "", ""
+	if localURL == "" {
+		return creds
+	}
+	token, err := sessionToken(sessionID, time.Now().Add(sessionTokenTTL))
+	if err != nil {
+		return creds
+	}
+	creds.TokenURL = fmt.Sprintf("%s/api/sessions/%s/credentials", localURL, sessionID)
+	creds.TokenKey = token
+	return creds
+}
+
+// HandleCredentials serves the current HTTPS username and token of a running
+// session's repository to the session's askpass helper, which authenticates
+// with a sessionToken.
+func (h *SessionsHandler) HandleCredentials(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ok, err := checkSessionToken(id, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "invalid or expired credentials token")
+		return
+	}
+
+	var host, status string
+	err = h.db.QueryRow(`SELECT r.host, s.status FROM sessions s JOIN repositories r ON r.id = s.repo_id WHERE s.id = ?`, id).
+		Scan(&host, &status)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status != "running" {
+		WriteError(w, http.StatusUnauthorized, "session is not running")
+		return
+	}
+	creds := hostCredentials(h.db, host)
+	WriteJSON(w, http.StatusOK, map[string]string{"username": creds.Username, "token": creds.Token})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckSessionToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".superposition"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := sessionToken("abc123", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := sessionToken("abc123", time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		sessionID string
+		token     string
+		want      bool
+	}{
+		{"valid", "abc123", valid, true},
+		{"other session", "def456", valid, false},
+		{"expired", "abc123", expired, false},
+		{"later expiry", "abc123", "9999999999" + valid[len(valid)-65:], false},
+		{"empty", "abc123", "", false},
+		{"no expiry", "abc123", "deadbeef", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checkSessionToken(tt.sessionID, tt.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("checkSessionToken(%q, %q) = %v, want %v", tt.sessionID, tt.token, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peterje/superposition/internal/git"
+	"github.com/peterje/superposition/internal/models"
+)
+
+type CommentsHandler struct {
+	db *sql.DB
+}
+
+func NewCommentsHandler(db *sql.DB) *CommentsHandler {
+	return &CommentsHandler{db: db}
+}
+
+func (h *CommentsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if !h.sessionExists(sessionID) {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	rows, err := h.db.Query(`SELECT id, session_id, path, side, line, line_content, body, outdated, created_at, updated_at
+		FROM review_comments WHERE session_id = ? ORDER BY path, line, id`, sessionID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rows.Close()
+
+	comments := []models.ReviewComment{}
+	for rows.Next() {
+		var c models.ReviewComment
+		if err := rows.Scan(&c.ID, &c.SessionID, &c.Path, &c.Side, &c.Line, &c.LineContent, &c.Body, &c.Outdated,
+			&c.CreatedAt, &c.UpdatedAt); err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		comments = append(comments, c)
+	}
+	rows.Close()
+
+	// Re-check anchors against the current diff. If the worktree is gone
+	// (e.g. the session was cleaned up) we just return what we have.
+	if diff, err := loadSessionDiff(h.db, sessionID, git.DiffAll, git.DiffWithMode); err == nil {
+		for i := range comments {
+			c := &comments[i]
+			if c.Outdated {
+				continue
+			}
+			line := findDiffLine(diff, c.Path, c.Side, c.Line)
+			if line == nil || line.Content != c.LineContent {
+				c.Outdated = true
+				if _, err := h.db.Exec(`UPDATE review_comments SET outdated = 1 WHERE id = ?`, c.ID); err != nil {
+					log.Printf("Failed to mark comment %d outdated: %v", c.ID, err)
+				}
+			}
+		}
+	}
+
+	WriteJSON(w, http.StatusOK, comments)
+}
+
+func (h *CommentsHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+
+	var body struct {
+		Path string `json:"path"`
+		Side string `json:"side"`
+		Line int    `json:"line"`
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if body.Path == "" {
+		WriteError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	if body.Side != "old" && body.Side != "new" {
+		WriteError(w, http.StatusBadRequest, "side must be 'old' or 'new'")
+		return
+	}
+	if body.Line <= 0 {
+		WriteError(w, http.StatusBadRequest, "line must be positive")
+		return
+	}
+	if strings.TrimSpace(body.Body) == "" {
+		WriteError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	diff, err := loadSessionDiff(h.db, sessionID, git.DiffAll, git.DiffWithMode)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	line := findDiffLine(diff, body.Path, body.Side, body.Line)
+	if line == nil {
+		WriteError(w, http.StatusBadRequest, "line not found in diff")
+		return
+	}
+
+	now := time.Now()
+	result, err := h.db.Exec(`INSERT INTO review_comments (session_id, path, side, line, line_content, body, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, body.Path, body.Side, body.Line, line.Content, body.Body, now, now)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	WriteJSON(w, http.StatusCreated, models.ReviewComment{
+		ID:          id,
+		SessionID:   sessionID,
+		Path:        body.Path,
+		Side:        body.Side,
+		Line:        body.Line,
+		LineContent: line.Content,
+		Body:        body.Body,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+}
+
+func (h *CommentsHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	commentID, err := strconv.ParseInt(r.PathValue("commentId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid comment id")
+		return
+	}
+
+	var body struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	if strings.TrimSpace(body.Body) == "" {
+		WriteError(w, http.StatusBadRequest, "body is required")
+		return
+	}
+
+	now := time.Now()
+	result, err := h.db.Exec(`UPDATE review_comments SET body = ?, updated_at = ? WHERE id = ? AND session_id = ?`,
+		body.Body, now, commentID, sessionID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		WriteError(w, http.StatusNotFound, "comment not found")
+		return
+	}
+
+	var c models.ReviewComment
+	err = h.db.QueryRow(`SELECT id, session_id, path, side, line, line_content, body, outdated, created_at, updated_at
+		FROM review_comments WHERE id = ?`, commentID).
+		Scan(&c.ID, &c.SessionID, &c.Path, &c.Side, &c.Line, &c.LineContent, &c.Body, &c.Outdated, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *CommentsHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	commentID, err := strconv.ParseInt(r.PathValue("commentId"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid comment id")
+		return
+	}
+
+	result, err := h.db.Exec(`DELETE FROM review_comments WHERE id = ? AND session_id = ?`, commentID, sessionID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		WriteError(w, http.StatusNotFound, "comment not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *CommentsHandler) sessionExists(id string) bool {
+	var exists int
+	err := h.db.QueryRow(`SELECT 1 FROM sessions WHERE id = ?`, id).Scan(&exists)
+	return err == nil
+}
+
+// findDiffLine returns the diff line anchored at the given file path, side
+// and line number, or nil if the current diff no longer contains it.
+func findDiffLine(diff *git.DiffResult, path, side string, lineNum int) *git.DiffLine {
+	for fi := range diff.Files {
+		f := &diff.Files[fi]
+		if f.Path != path && !(side == "old" && f.OldPath == path) {
+			continue
+		}
+		for hi := range f.Hunks {
+			for li := range f.Hunks[hi].Lines {
+				l := &f.Hunks[hi].Lines[li]
+				if side == "new" && l.Type != "delete" && l.NewNum == lineNum {
+					return l
+				}
+				if side == "old" && l.Type != "add" && l.OldNum == lineNum {
+					return l
+				}
+			}
+		}
+	}
+	return nil
+}
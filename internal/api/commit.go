@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+// HandleStatus reports the session worktree's staged, unstaged and untracked
+// files.
+func (h *SessionsHandler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	worktreePath, _, err := loadSessionBase(h.db, r.PathValue("id"))
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	entries, err := git.Status(worktreePath)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, entries)
+}
+
+// authorIdentity returns the configured identity for commits made through
+// the API.
+func authorIdentity(db *sql.DB) git.Identity {
+	var id git.Identity
+	db.QueryRow(`SELECT value FROM settings WHERE key = 'git.author_name'`).Scan(&id.Name)
+	db.QueryRow(`SELECT value FROM settings WHERE key = 'git.author_email'`).Scan(&id.Email)
+	return id
+}
+
+// HandleCommit stages and commits work in the session worktree. The body may
+// give a "message" (generated from the staged files if empty) and "paths" to
+// limit what is staged; without paths everything is staged. Commits use the
+// git.author_name and git.author_email settings when set.
+func (h *SessionsHandler) HandleCommit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var body struct {
+		Message string   `json:"message"`
+		Paths   []string `json:"paths"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			WriteError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+	paths := make([]string, 0, len(body.Paths))
+	for _, p := range body.Paths {
+		clean, err := cleanRelPath(p)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		paths = append(paths, clean)
+	}
+
+	worktreePath, _, err := loadSessionBase(h.db, id)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := git.Stage(worktreePath, paths); err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	message := strings.TrimSpace(body.Message)
+	if message == "" {
+		staged, err := git.StagedFiles(worktreePath)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(staged) == 0 {
+			WriteError(w, http.StatusConflict, git.ErrNothingToCommit.Error())
+			return
+		}
+		message = git.CommitMessage(staged)
+	}
+
+	sha, err := git.CommitStaged(worktreePath, message, authorIdentity(h.db))
+	if errors.Is(err, git.ErrNothingToCommit) {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("Session %s: committed %s", id, sha)
+	WriteJSON(w, http.StatusCreated, map[string]string{"sha": sha, "message": message})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+// HandleCommits lists the commits on the session branch since its base
+// commit, newest first.
+func (h *SessionsHandler) HandleCommits(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	worktreePath, baseCommit, err := loadSessionBase(h.db, id)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if baseCommit == "" {
+		WriteJSON(w, http.StatusOK, []git.Commit{})
+		return
+	}
+
+	commits, err := git.Log(worktreePath, baseCommit, "HEAD")
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, commits)
+}
+
+// HandleCommitDiff returns the diff of a single commit on the session branch.
+func (h *SessionsHandler) HandleCommitDiff(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	sha := r.PathValue("sha")
+	if !git.ValidSHA(sha) {
+		WriteError(w, http.StatusBadRequest, "invalid commit sha")
+		return
+	}
+
+	worktreePath, baseCommit, err := loadSessionBase(h.db, id)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Only serve commits the session made: on HEAD but not in the base.
+	if !git.IsAncestor(worktreePath, sha, "HEAD") || (baseCommit != "" && git.IsAncestor(worktreePath, sha, baseCommit)) {
+		WriteError(w, http.StatusNotFound, "commit not found on session branch")
+		return
+	}
+
+	diff, err := git.CommitDiff(worktreePath, sha)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, diff)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+type deployKey struct {
+	PublicKey string `json:"public_key"`
+}
+
+// HandleDeployKey returns the repository's SSH deploy key, or 404 if it has
+// none.
+func (h *ReposHandler) HandleDeployKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.deployKeyRepo(w, r)
+	if !ok {
+		return
+	}
+	pub, _, err := git.DeployKey(id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if pub == "" {
+		WriteError(w, http.StatusNotFound, "repository has no deploy key")
+		return
+	}
+	WriteJSON(w, http.StatusOK, deployKey{PublicKey: pub})
+}
+
+// HandleGenerateDeployKey creates a new SSH deploy key for the repository,
+// replacing any existing one, and returns its public key to add to the git
+// host. The key is used for all of the repository's git operations over SSH,
+// including pushes from session worktrees.
+func (h *ReposHandler) HandleGenerateDeployKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.deployKeyRepo(w, r)
+	if !ok {
+		return
+	}
+	pub, err := git.GenerateDeployKey(id)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusCreated, deployKey{PublicKey: pub})
+}
+
+// HandleDeleteDeployKey removes the repository's deploy key.
+func (h *ReposHandler) HandleDeleteDeployKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.deployKeyRepo(w, r)
+	if !ok {
+		return
+	}
+	if err := git.RemoveDeployKey(id); err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deployKeyRepo parses the repo id and checks that it is a remote repository.
+func (h *ReposHandler) deployKeyRepo(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid id")
+		return 0, false
+	}
+	var repoType string
+	err = h.db.QueryRow(`SELECT repo_type FROM repositories WHERE id = ?`, id).Scan(&repoType)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "repository not found")
+		return 0, false
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return 0, false
+	}
+	if repoType == "local" {
+		WriteError(w, http.StatusBadRequest, "local repositories don't use deploy keys")
+		return 0, false
+	}
+	return id, true
+}
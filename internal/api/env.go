@@ -0,0 +1,147 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/peterje/superposition/internal/agents"
+	"github.com/peterje/superposition/internal/models"
+)
+
+// Per-repo environment profiles are stored as settings with the key
+// "repo_env.<repo id>" and a JSON array of models.EnvVar as the value.
+const repoEnvPrefix = "repo_env."
+
+// secretMask replaces secret values whenever a profile is read back.
+const secretMask = "********"
+
+func repoEnvKey(repoID int64) string {
+	return repoEnvPrefix + strconv.FormatInt(repoID, 10)
+}
+
+// loadRepoEnv returns the environment profile for a repo, or nil if none.
+func loadRepoEnv(db *sql.DB, repoID int64) ([]models.EnvVar, error) {
+	var val string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, repoEnvKey(repoID)).Scan(&val)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var vars []models.EnvVar
+	if err := json.Unmarshal([]byte(val), &vars); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", repoEnvKey(repoID), err)
+	}
+	return vars, nil
+}
+
+// buildSessionEnv layers a session's environment: the agent's defaults, then
+// the repo's git credentials, then the repo's profile, then the per-session
+// variables. Later entries win.
+func buildSessionEnv(db *sql.DB, agent agents.Agent, sessionID string, repoID int64, sessionEnv map[string]string) ([]string, error) {
+	profile, err := loadRepoEnv(db, repoID)
+	if err != nil {
+		return nil, err
+	}
+	var host string
+	if err := db.QueryRow(`SELECT host FROM repositories WHERE id = ?`, repoID).Scan(&host); err != nil {
+		return nil, err
+	}
+	env := agent.Environ()
+	env = append(env, sessionCredentials(db, sessionID, repoID, host).Env()...)
+	for _, v := range profile {
+		env = append(env, v.Name+"="+v.Value)
+	}
+	return append(env, envPairs(sessionEnv)...), nil
+}
+
+// validateEnvName rejects names that can't be used as environment variables.
+func validateEnvName(name string) error {
+	if name == "" {
+		return fmt.Errorf("env var name is required")
+	}
+	if strings.ContainsAny(name, "=\x00") {
+		return fmt.Errorf("invalid env var name %q", name)
+	}
+	return nil
+}
+
+// envPairs converts a map of env vars to sorted KEY=VALUE pairs.
+func envPairs(env map[string]string) []string {
+	pairs := make([]string, 0, len(env))
+	for k, v := range env {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// maskSetting hides secret values in environment profile and host settings.
+// Other settings are returned unchanged.
+func maskSetting(s models.Setting) models.Setting {
+	if strings.HasPrefix(s.Key, hostPrefix) {
+		return maskHostSetting(s)
+	}
+	if !strings.HasPrefix(s.Key, repoEnvPrefix) {
+		return s
+	}
+	var vars []models.EnvVar
+	if err := json.Unmarshal([]byte(s.Value), &vars); err != nil {
+		return s
+	}
+	for i := range vars {
+		if vars[i].Secret {
+			vars[i].Value = secretMask
+		}
+	}
+	masked, _ := json.Marshal(vars)
+	s.Value = string(masked)
+	return s
+}
+
+// prepareEnvSetting validates an environment profile being written and
+// restores secret values the client sent back masked, so a profile can be
+// round-tripped through GET and PUT without losing its secrets.
+func prepareEnvSetting(db *sql.DB, key, value string) (string, error) {
+	var vars []models.EnvVar
+	if err := json.Unmarshal([]byte(value), &vars); err != nil {
+		return "", fmt.Errorf("%s must be a JSON array of {name, value, secret}", key)
+	}
+
+	var existing []models.EnvVar
+	var stored string
+	if err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&stored); err == nil {
+		json.Unmarshal([]byte(stored), &existing)
+	}
+
+	seen := make(map[string]bool, len(vars))
+	for i := range vars {
+		if err := validateEnvName(vars[i].Name); err != nil {
+			return "", err
+		}
+		if seen[vars[i].Name] {
+			return "", fmt.Errorf("duplicate env var %q", vars[i].Name)
+		}
+		seen[vars[i].Name] = true
+
+		if vars[i].Secret && vars[i].Value == secretMask {
+			for _, e := range existing {
+				if e.Name == vars[i].Name && e.Secret {
+					vars[i].Value = e.Value
+					break
+				}
+			}
+		}
+	}
+
+	out, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
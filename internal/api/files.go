@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+const (
+	maxFileSize  = 10 * 1024 * 1024 // refuse to serve files larger than this
+	maxFileLines = 5000             // most lines returned by one request
+)
+
+// errFileTooLarge is returned by readWorktreeFile for files over maxFileSize.
+var errFileTooLarge = errors.New("file too large")
+
+type fileLines struct {
+	Path       string   `json:"path"`
+	Ref        string   `json:"ref"`
+	Start      int      `json:"start"`
+	End        int      `json:"end"`
+	TotalLines int      `json:"total_lines"`
+	Lines      []string `json:"lines"`
+}
+
+// HandleFile returns a 1-based, inclusive line range of a file in the session,
+// either as of the base commit (?ref=base) or in the worktree (?ref=worktree,
+// the default). The diff viewer uses it to expand context around hunks.
+func (h *SessionsHandler) HandleFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
+
+	path, err := cleanRelPath(q.Get("path"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ref := q.Get("ref")
+	if ref == "" {
+		ref = "worktree"
+	}
+	if ref != "worktree" && ref != "base" {
+		WriteError(w, http.StatusBadRequest, "ref must be base or worktree")
+		return
+	}
+	start, end, err := parseLineRange(q.Get("start"), q.Get("end"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	worktreePath, baseCommit, err := loadSessionBase(h.db, id)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var content []byte
+	if ref == "base" {
+		if baseCommit == "" {
+			WriteError(w, http.StatusNotFound, "session has no base commit")
+			return
+		}
+		content, err = git.ShowFile(worktreePath, baseCommit, path)
+		if err != nil {
+			WriteError(w, http.StatusNotFound, "file not found at base commit")
+			return
+		}
+	} else {
+		content, err = readWorktreeFile(worktreePath, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			WriteError(w, http.StatusNotFound, "file not found in worktree")
+			return
+		}
+		if errors.Is(err, errFileTooLarge) {
+			WriteError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if len(content) > maxFileSize {
+		WriteError(w, http.StatusRequestEntityTooLarge, errFileTooLarge.Error())
+		return
+	}
+	if bytes.IndexByte(content, 0) >= 0 {
+		WriteError(w, http.StatusUnsupportedMediaType, "binary file")
+		return
+	}
+
+	lines := strings.Split(string(content), "\n")
+	// A trailing newline terminates the last line rather than starting one.
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if end == 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if end-start+1 > maxFileLines {
+		end = start + maxFileLines - 1
+	}
+	result := fileLines{Path: path, Ref: ref, Start: start, End: end, TotalLines: len(lines), Lines: []string{}}
+	if start <= end {
+		result.Lines = lines[start-1 : end]
+	} else {
+		result.End = start - 1
+	}
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// cleanRelPath validates a repo-relative path so it can't point outside the
+// worktree.
+func cleanRelPath(p string) (string, error) {
+	if p == "" {
+		return "", errors.New("path is required")
+	}
+	if filepath.IsAbs(p) || strings.HasPrefix(p, "/") {
+		return "", errors.New("path must be relative")
+	}
+	clean := filepath.ToSlash(filepath.Clean(p))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", errors.New("path escapes the worktree")
+	}
+	if clean == ".git" || strings.HasPrefix(clean, ".git/") {
+		return "", errors.New("path is inside .git")
+	}
+	return clean, nil
+}
+
+// readWorktreeFile reads path inside worktreePath, refusing symlinks that
+// resolve outside the worktree.
+func readWorktreeFile(worktreePath, path string) ([]byte, error) {
+	root, err := filepath.EvalSymlinks(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, errors.New("path escapes the worktree")
+	}
+
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errors.New("not a regular file")
+	}
+	if info.Size() > maxFileSize {
+		return nil, errFileTooLarge
+	}
+	return os.ReadFile(full)
+}
+
+// parseLineRange parses optional 1-based start/end parameters. end is 0 when
+// not given, meaning the end of the file.
+func parseLineRange(startStr, endStr string) (int, int, error) {
+	start, end := 1, 0
+	if startStr != "" {
+		n, err := strconv.Atoi(startStr)
+		if err != nil || n < 1 {
+			return 0, 0, errors.New("start must be a positive line number")
+		}
+		start = n
+	}
+	if endStr != "" {
+		n, err := strconv.Atoi(endStr)
+		if err != nil || n < 1 {
+			return 0, 0, errors.New("end must be a positive line number")
+		}
+		if n < start {
+			return 0, 0, errors.New("end must not be before start")
+		}
+		end = n
+	}
+	return start, end, nil
+}
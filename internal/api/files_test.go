@@ -0,0 +1,36 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadWorktreeFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	big, err := os.Create(filepath.Join(dir, "big.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := big.Truncate(maxFileSize + 1); err != nil {
+		t.Fatal(err)
+	}
+	big.Close()
+
+	tests := []struct {
+		path string
+		want error
+	}{
+		{"big.bin", errFileTooLarge},
+		{"missing.txt", fs.ErrNotExist},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if _, err := readWorktreeFile(dir, tt.path); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/peterje/superposition/internal/git"
+	"github.com/peterje/superposition/internal/github"
+	"github.com/peterje/superposition/internal/models"
+)
+
+// Git hosts are configured as settings with the key "host.<hostname>" and a
+// JSON models.Host as the value, e.g.
+//
+//	host.git.example.com = {"kind": "gitlab", "token": "glpat-..."}
+//
+// github.com is built in and uses the github_pat setting as its token.
+// GitHub hosts can authenticate as a GitHub App installation instead, with
+// app_id, installation_id and private_key; installation tokens are minted
+// from those and refreshed before they expire.
+// Hostnames without a setting are treated as plain git remotes without
+// credentials.
+const (
+	hostPrefix  = "host."
+	defaultHost = "github.com"
+)
+
+// Host kinds. Repositories on github hosts are listed and get pull requests
+// through the GitHub API; the others are cloned and synced only.
+const (
+	hostGitHub = "github"
+	hostGitLab = "gitlab"
+	hostGitea  = "gitea"
+	hostGit    = "git"
+)
+
+// loadHost returns the configuration for a hostname, with defaults filled in.
+func loadHost(db *sql.DB, name string) (models.Host, error) {
+	host := models.Host{Name: name, Kind: hostGit}
+	if name == defaultHost {
+		host.Kind = hostGitHub
+	}
+
+	var val string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, hostPrefix+name).Scan(&val)
+	if err != nil && err != sql.ErrNoRows {
+		return host, err
+	}
+	if err == nil {
+		if err := json.Unmarshal([]byte(val), &host); err != nil {
+			return host, fmt.Errorf("parse %s%s: %w", hostPrefix, name, err)
+		}
+		host.Name = name
+	}
+
+	if name == defaultHost && host.Token == "" && host.AppID == 0 {
+		host.Token = githubPAT(db)
+	}
+	fillHostDefaults(&host)
+	if host.Kind == hostGitHub && host.AppID != 0 {
+		token, err := appToken(host)
+		if err != nil {
+			return host, fmt.Errorf("%s: %w", name, err)
+		}
+		host.Token = token
+	}
+	return host, nil
+}
+
+var (
+	appTokensMu sync.Mutex
+	appTokens   = map[string]*appTokenSource{} // by host name
+)
+
+// appTokenSource remembers the host config a token source was built from, so
+// changing the host setting replaces it.
+type appTokenSource struct {
+	config models.Host
+	source *github.AppTokenSource
+}
+
+// appToken returns an installation token for a host configured with a GitHub
+// App, reusing the cached token until it is close to expiry.
+func appToken(host models.Host) (string, error) {
+	appTokensMu.Lock()
+	cached, ok := appTokens[host.Name]
+	config := host
+	config.Token = ""
+	if !ok || cached.config != config {
+		source, err := github.NewAppTokenSource(host.APIURL, host.AppID, host.InstallationID, host.PrivateKey)
+		if err != nil {
+			appTokensMu.Unlock()
+			return "", err
+		}
+		cached = &appTokenSource{config: config, source: source}
+		appTokens[host.Name] = cached
+	}
+	appTokensMu.Unlock()
+	return cached.source.Token()
+}
+
+func fillHostDefaults(host *models.Host) {
+	if host.WebURL == "" {
+		host.WebURL = "https://" + host.Name
+	}
+	host.WebURL = strings.TrimRight(host.WebURL, "/")
+	if host.APIURL == "" {
+		switch {
+		case host.Kind == hostGitHub && host.Name == defaultHost:
+			host.APIURL = github.DefaultAPIURL
+		case host.Kind == hostGitHub:
+			host.APIURL = host.WebURL + "/api/v3" // GitHub Enterprise Server
+		case host.Kind == hostGitLab:
+			host.APIURL = host.WebURL + "/api/v4"
+		case host.Kind == hostGitea:
+			host.APIURL = host.WebURL + "/api/v1"
+		}
+	}
+	if host.Username == "" {
+		switch host.Kind {
+		case hostGitHub:
+			host.Username = "x-access-token"
+		case hostGitLab:
+			host.Username = "oauth2"
+		default:
+			host.Username = "git"
+		}
+	}
+}
+
+// hostCredentials returns the HTTPS credentials for a repository's host.
+// Local repositories (no host) have none.
+func hostCredentials(db *sql.DB, name string) git.Credentials {
+	if name == "" {
+		return git.Credentials{}
+	}
+	host, err := loadHost(db, name)
+	if err != nil {
+		log.Printf("Credentials for %s: %v", name, err)
+		return git.Credentials{}
+	}
+	return git.Credentials{Username: host.Username, Token: host.Token}
+}
+
+// repoCredentials returns the credentials for a repository: its host's token
+// and its SSH deploy key, if it has one.
+func repoCredentials(db *sql.DB, repoID int64, host string) git.Credentials {
+	creds := hostCredentials(db, host)
+	if _, keyPath, err := git.DeployKey(repoID); err == nil {
+		creds.SSHKeyPath = keyPath
+	}
+	return creds
+}
+
+// githubClient returns an API client for a github-kind host.
+func githubClient(host models.Host) *github.Client {
+	return github.NewClient(host.APIURL, host.Token)
+}
+
+var scpLikeURL = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+
+// remoteRepo is a repository URL broken into the parts we store.
+type remoteRepo struct {
+	Host     string // hostname, with port if any
+	Owner    string // may contain slashes for GitLab subgroups
+	Name     string
+	CloneURL string
+}
+
+// parseRepoURL accepts owner/name (on github.com), host/owner/name,
+// http(s)://host/owner/name[.git] and SSH remotes, either git@host:owner/name
+// or ssh://[user@]host[:port]/owner/name. HTTPS remotes are normalized;
+// SSH remotes are cloned as given.
+func parseRepoURL(raw string) (remoteRepo, error) {
+	raw = strings.TrimSpace(raw)
+	var repo remoteRepo
+	var path, scheme string
+
+	if m := scpLikeURL.FindStringSubmatch(raw); m != nil && !strings.Contains(raw, "://") {
+		repo.Host, path = m[1], m[2]
+		repo.CloneURL = raw
+	} else if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			return repo, fmt.Errorf("invalid repository URL %q", raw)
+		}
+		repo.Host, path, scheme = u.Host, u.Path, u.Scheme
+		if scheme == "ssh" {
+			repo.Host = u.Hostname()
+			repo.CloneURL = raw
+		} else if scheme != "https" && scheme != "http" {
+			return repo, fmt.Errorf("unsupported URL scheme %q", scheme)
+		}
+	} else {
+		// owner/name or host/owner/name
+		parts := strings.SplitN(strings.Trim(raw, "/"), "/", 2)
+		if len(parts) == 2 && strings.Contains(parts[0], ".") {
+			repo.Host, path = parts[0], parts[1]
+		} else {
+			repo.Host, path = defaultHost, raw
+		}
+		scheme = "https"
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	i := strings.LastIndex(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		return repo, fmt.Errorf("invalid repository URL %q: need owner/name", raw)
+	}
+	repo.Owner, repo.Name = path[:i], path[i+1:]
+	if repo.CloneURL == "" {
+		repo.CloneURL = fmt.Sprintf("%s://%s/%s/%s.git", scheme, repo.Host, repo.Owner, repo.Name)
+	}
+	return repo, nil
+}
+
+// maskHostSetting hides the token and app private key of a host setting.
+func maskHostSetting(s models.Setting) models.Setting {
+	var host models.Host
+	if err := json.Unmarshal([]byte(s.Value), &host); err != nil || (host.Token == "" && host.PrivateKey == "") {
+		return s
+	}
+	if host.Token != "" {
+		host.Token = secretMask
+	}
+	if host.PrivateKey != "" {
+		host.PrivateKey = secretMask
+	}
+	masked, _ := json.Marshal(host)
+	s.Value = string(masked)
+	return s
+}
+
+// prepareHostSetting validates a host setting being written and restores a
+// token or private key the client sent back masked.
+func prepareHostSetting(db *sql.DB, key, value string) (string, error) {
+	var host models.Host
+	if err := json.Unmarshal([]byte(value), &host); err != nil {
+		return "", fmt.Errorf("%s must be a JSON object of {kind, web_url, api_url, username, token}", key)
+	}
+	host.Name = strings.TrimPrefix(key, hostPrefix)
+	if host.Name == "" {
+		return "", fmt.Errorf("host name is required")
+	}
+	switch host.Kind {
+	case hostGitHub, hostGitLab, hostGitea, hostGit:
+	default:
+		return "", fmt.Errorf("host kind must be github, gitlab, gitea or git")
+	}
+	for _, u := range []string{host.WebURL, host.APIURL} {
+		if u == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return "", fmt.Errorf("invalid URL %q", u)
+		}
+	}
+
+	if host.Token == secretMask || host.PrivateKey == secretMask {
+		var existing models.Host
+		var stored string
+		if err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&stored); err == nil {
+			json.Unmarshal([]byte(stored), &existing)
+		}
+		if host.Token == secretMask {
+			host.Token = existing.Token
+		}
+		if host.PrivateKey == secretMask {
+			host.PrivateKey = existing.PrivateKey
+		}
+	}
+
+	if host.AppID != 0 || host.InstallationID != 0 || host.PrivateKey != "" {
+		if host.Kind != hostGitHub {
+			return "", fmt.Errorf("GitHub App credentials need a github host")
+		}
+		if host.AppID == 0 || host.InstallationID == 0 || host.PrivateKey == "" {
+			return "", fmt.Errorf("GitHub App needs app_id, installation_id and private_key")
+		}
+		if _, err := github.ParseAppPrivateKey(host.PrivateKey); err != nil {
+			return "", err
+		}
+	}
+
+	out, err := json.Marshal(host)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import "testing"
+
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want remoteRepo
+	}{
+		{"acme/widgets", remoteRepo{"github.com", "acme", "widgets", "https://github.com/acme/widgets.git"}},
+		{"ghe.example.com/acme/widgets", remoteRepo{"ghe.example.com", "acme", "widgets", "https://ghe.example.com/acme/widgets.git"}},
+		{"https://github.com/acme/widgets.git", remoteRepo{"github.com", "acme", "widgets", "https://github.com/acme/widgets.git"}},
+		{"https://github.com/acme/widgets/", remoteRepo{"github.com", "acme", "widgets", "https://github.com/acme/widgets.git"}},
+		{"http://git.local:3000/acme/widgets", remoteRepo{"git.local:3000", "acme", "widgets", "http://git.local:3000/acme/widgets.git"}},
+		{"git@github.com:acme/widgets.git", remoteRepo{"github.com", "acme", "widgets", "git@github.com:acme/widgets.git"}},
+		{"ssh://git@gitlab.example.com:2222/acme/widgets.git", remoteRepo{"gitlab.example.com", "acme", "widgets", "ssh://git@gitlab.example.com:2222/acme/widgets.git"}},
+		{"https://gitlab.com/acme/platform/tools/widgets", remoteRepo{"gitlab.com", "acme/platform/tools", "widgets", "https://gitlab.com/acme/platform/tools/widgets.git"}},
+		{"git@gitlab.com:acme/platform/widgets.git", remoteRepo{"gitlab.com", "acme/platform", "widgets", "git@gitlab.com:acme/platform/widgets.git"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseRepoURL(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRepoURLErrors(t *testing.T) {
+	for _, raw := range []string{
+		"widgets",
+		"https://github.com/acme",
+		"ftp://github.com/acme/widgets",
+		"git@github.com:widgets",
+	} {
+		if got, err := parseRepoURL(raw); err == nil {
+			t.Errorf("parseRepoURL(%q) = %+v, want an error", raw, got)
+		}
+	}
+}
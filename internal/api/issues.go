@@ -0,0 +1,64 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/peterje/superposition/internal/github"
+	"github.com/peterje/superposition/internal/models"
+)
+
+// repoGitHubClient returns an API client for a repository on a GitHub host,
+// or an error and the HTTP status to report it with.
+func repoGitHubClient(db *sql.DB, repo models.Repository) (*github.Client, int, error) {
+	if repo.RepoType != "github" {
+		return nil, http.StatusBadRequest, errors.New("issues and pull requests are only supported for repositories on GitHub hosts")
+	}
+	host, err := loadHost(db, repo.Host)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if host.Token == "" {
+		return nil, http.StatusBadRequest, fmt.Errorf("no token configured for %s", repo.Host)
+	}
+	return githubClient(host), 0, nil
+}
+
+// githubErrorStatus maps a failed GitHub API call to the status we answer
+// with: 404 if GitHub had no such issue or pull request, 502 otherwise.
+func githubErrorStatus(err error) int {
+	var apiErr *github.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadGateway
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// issueBranch names a session branch after an issue, e.g.
+// "issue-42-fix-login-redirect".
+func issueBranch(issue *github.Issue) string {
+	slug := nonSlugChars.ReplaceAllString(strings.ToLower(issue.Title), "-")
+	if len(slug) > 40 {
+		slug = slug[:40]
+	}
+	slug = strings.Trim(slug, "-")
+	if slug == "" {
+		return fmt.Sprintf("issue-%d", issue.Number)
+	}
+	return fmt.Sprintf("issue-%d-%s", issue.Number, slug)
+}
+
+// issuePrompt is the initial prompt for a session started from an issue.
+func issuePrompt(issue *github.Issue) string {
+	prompt := fmt.Sprintf("Resolve GitHub issue #%d: %s\n%s", issue.Number, issue.Title, issue.HTMLURL)
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		prompt += "\n\n" + body
+	}
+	return prompt
+}
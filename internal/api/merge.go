@@ -0,0 +1,182 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+// HandleMerge brings a local-folder session's branch into its source branch
+// and publishes the result to the repository at source_path. The body picks
+// a "strategy" (merge, squash or rebase; default merge) and an optional
+// commit "message". Conflicts abort the operation and are reported as a
+// 409 with the conflicting files.
+func (h *SessionsHandler) HandleMerge(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var body struct {
+		Strategy string `json:"strategy"`
+		Message  string `json:"message"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			WriteError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+	strategy := git.MergeStrategy(body.Strategy)
+	switch strategy {
+	case "":
+		strategy = git.StrategyMerge
+	case git.StrategyMerge, git.StrategySquash, git.StrategyRebase:
+	default:
+		WriteError(w, http.StatusBadRequest, "strategy must be merge, squash or rebase")
+		return
+	}
+
+	var branch, sourceBranch, baseCommit, repoType, localPath string
+	var repoID int64
+	err := h.db.QueryRow(`SELECT s.branch, s.source_branch, s.base_commit, r.id, r.repo_type, r.local_path
+		FROM sessions s JOIN repositories r ON s.repo_id = r.id WHERE s.id = ?`, id).
+		Scan(&branch, &sourceBranch, &baseCommit, &repoID, &repoType, &localPath)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if repoType != "local" {
+		WriteError(w, http.StatusBadRequest, "merging is only supported for local repositories; open a pull request instead")
+		return
+	}
+	if sourceBranch == "" {
+		WriteError(w, http.StatusBadRequest, "session has no source branch to merge into")
+		return
+	}
+
+	// Merge against the latest state of the source branch.
+	if err := git.Fetch(localPath, git.Credentials{}, sessionBranches(h.db, repoID)); err != nil {
+		WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	message := strings.TrimSpace(body.Message)
+	if message == "" {
+		message = defaultMergeMessage(localPath, branch, sourceBranch, baseCommit, strategy)
+	}
+
+	commit, err := git.MergeInto(localPath, branch, sourceBranch, strategy, message, authorIdentity(h.db))
+	var conflict *git.ConflictError
+	if errors.As(err, &conflict) {
+		WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":     conflict.Error(),
+			"conflicts": conflict.Files,
+		})
+		return
+	}
+	if errors.Is(err, git.ErrNothingToCommit) {
+		WriteError(w, http.StatusConflict, "session branch has no changes to merge")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := git.PushToOrigin(localPath, commit, sourceBranch); err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+	// Pick up the new source branch tip in the bare clone.
+	git.Fetch(localPath, git.Credentials{}, sessionBranches(h.db, repoID))
+
+	log.Printf("Session %s: %s %s into %s (%s)", id, strategy, branch, sourceBranch, commit)
+	WriteJSON(w, http.StatusOK, map[string]string{
+		"strategy":      string(strategy),
+		"commit":        commit,
+		"source_branch": sourceBranch,
+	})
+}
+
+// defaultMergeMessage describes the merge; squash commits list the subjects
+// of the commits they replace.
+func defaultMergeMessage(barePath, branch, target, baseCommit string, strategy git.MergeStrategy) string {
+	if strategy != git.StrategySquash {
+		return fmt.Sprintf("Merge branch '%s' into %s", branch, target)
+	}
+	var b strings.Builder
+	b.WriteString(branch)
+	if baseCommit != "" {
+		if commits, err := git.Log(barePath, baseCommit, "refs/heads/"+branch); err == nil && len(commits) > 0 {
+			b.WriteString("\n\n")
+			for i := len(commits) - 1; i >= 0; i-- {
+				fmt.Fprintf(&b, "* %s\n", commits[i].Subject)
+			}
+		}
+	}
+	return b.String()
+}
+
+// HandleRebase fetches the repository and rebases the session branch onto
+// the latest origin/<source_branch> inside its worktree, moving the session's
+// base commit along with it. Conflicts abort the rebase and are reported as
+// a 409 with the conflicting files.
+func (h *SessionsHandler) HandleRebase(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var worktreePath, sourceBranch, host, localPath string
+	var repoID int64
+	err := h.db.QueryRow(`SELECT s.worktree_path, s.source_branch, r.id, r.host, r.local_path
+		FROM sessions s JOIN repositories r ON s.repo_id = r.id WHERE s.id = ?`, id).
+		Scan(&worktreePath, &sourceBranch, &repoID, &host, &localPath)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if sourceBranch == "" {
+		WriteError(w, http.StatusBadRequest, "session has no source branch to rebase onto")
+		return
+	}
+
+	if err := git.Fetch(localPath, repoCredentials(h.db, repoID, host), sessionBranches(h.db, repoID)); err != nil {
+		WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	upstream, err := git.ResolveCommit(localPath, "refs/remotes/origin/"+sourceBranch)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("source branch %q not found upstream", sourceBranch))
+		return
+	}
+
+	err = git.RebaseWorktree(worktreePath, upstream, authorIdentity(h.db))
+	var conflict *git.ConflictError
+	if errors.As(err, &conflict) {
+		WriteJSON(w, http.StatusConflict, map[string]any{
+			"error":     conflict.Error(),
+			"conflicts": conflict.Files,
+		})
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.db.Exec(`UPDATE sessions SET base_commit = ? WHERE id = ?`, upstream, id)
+	log.Printf("Session %s: rebased onto %s (%s)", id, sourceBranch, upstream)
+	WriteJSON(w, http.StatusOK, map[string]string{"base_commit": upstream})
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+// repoProgress is the state of a repository's clone or sync, as streamed by
+// HandleProgress.
+type repoProgress struct {
+	Status  string `json:"status"` // cloning, syncing, ready or error
+	Phase   string `json:"phase,omitempty"`
+	Percent int    `json:"percent"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (p repoProgress) done() bool {
+	return p.Status == "ready" || p.Status == "error"
+}
+
+// progressHub tracks clones and syncs in flight and fans their progress out
+// to subscribers. Each subscriber channel holds only the latest update, so a
+// slow reader skips intermediate percentages but always sees the final state.
+type progressHub struct {
+	mu     sync.Mutex
+	active map[int64]repoProgress
+	subs   map[int64]map[chan repoProgress]struct{}
+}
+
+func newProgressHub() *progressHub {
+	return &progressHub{
+		active: make(map[int64]repoProgress),
+		subs:   make(map[int64]map[chan repoProgress]struct{}),
+	}
+}
+
+// publish records p as the repo's current state and notifies subscribers.
+// A final state (ready or error) ends the operation.
+func (h *progressHub) publish(id int64, p repoProgress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if p.done() {
+		delete(h.active, id)
+	} else {
+		h.active[id] = p
+	}
+	for ch := range h.subs[id] {
+		select {
+		case <-ch: // drop the unread update
+		default:
+		}
+		ch <- p
+	}
+}
+
+// reporter returns a git.ProgressFunc publishing updates under status.
+func (h *progressHub) reporter(id int64, status string) git.ProgressFunc {
+	return func(p git.Progress) {
+		h.publish(id, repoProgress{Status: status, Phase: p.Phase, Percent: p.Percent})
+	}
+}
+
+// subscribe returns the repo's current state and a channel of updates. ok is
+// false if no clone or sync is running, in which case ch is nil.
+func (h *progressHub) subscribe(id int64) (cur repoProgress, ch chan repoProgress, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cur, ok = h.active[id]
+	if !ok {
+		return cur, nil, false
+	}
+	ch = make(chan repoProgress, 1)
+	if h.subs[id] == nil {
+		h.subs[id] = make(map[chan repoProgress]struct{})
+	}
+	h.subs[id][ch] = struct{}{}
+	return cur, ch, true
+}
+
+func (h *progressHub) unsubscribe(id int64, ch chan repoProgress) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[id], ch)
+	if len(h.subs[id]) == 0 {
+		delete(h.subs, id)
+	}
+}
+
+// HandleProgress streams a repository's clone or sync progress as server-sent
+// events, one JSON repoProgress per "progress" event. The stream ends after
+// the ready or error event. If nothing is running, the stored state is sent
+// once.
+func (h *ReposHandler) HandleProgress(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	hub := h.syncer.progress
+	cur, ch, active := hub.subscribe(id)
+	if active {
+		defer hub.unsubscribe(id, ch)
+	} else {
+		var cloneError, syncError string
+		err := h.db.QueryRow(`SELECT clone_status, clone_error, last_sync_error FROM repositories WHERE id = ?`, id).
+			Scan(&cur.Status, &cloneError, &syncError)
+		if err == sql.ErrNoRows {
+			WriteError(w, http.StatusNotFound, "repository not found")
+			return
+		}
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		cur.Error = cloneError
+		if cur.Status == "ready" {
+			cur.Percent = 100
+			cur.Error = syncError
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(p repoProgress) {
+		data, _ := json.Marshal(p)
+		fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(cur)
+	if !active {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case p := <-ch:
+			send(p)
+			if p.done() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"time"
+
+	"github.com/peterje/superposition/internal/agents"
+	ptymgr "github.com/peterje/superposition/internal/pty"
+)
+
+// promptStdin returns what to pipe to a new session's stdin: the prompt for
+// agents that read it from there, otherwise nothing, leaving stdin on the
+// terminal.
+func promptStdin(agent agents.Agent, prompt string) string {
+	if prompt == "" || agent.PromptTemplate().Mode != agents.PromptStdin {
+		return ""
+	}
+	return prompt + "\n"
+}
+
+// sendInitialPrompt types prompt into a session that was just started, for
+// agents that take it in their UI rather than as arguments or on stdin. It
+// waits until the agent has had time to draw its UI, then sends it as a
+// bracketed paste so newlines don't submit it early, followed by Enter.
+func sendInitialPrompt(sess ptymgr.SessionHandle, agent agents.Agent, prompt string) {
+	t := agent.PromptTemplate()
+	if t.Mode != agents.PromptPTY {
+		return
+	}
+	go func() {
+		select {
+		case <-sess.Done():
+			return
+		case <-time.After(time.Duration(t.DelayMS) * time.Millisecond):
+		}
+		sess.Write([]byte("\x1b[200~" + prompt + "\x1b[201~"))
+		time.Sleep(100 * time.Millisecond)
+		sess.Write([]byte("\r"))
+	}()
+}
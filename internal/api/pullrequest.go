@@ -0,0 +1,150 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/peterje/superposition/internal/git"
+	"github.com/peterje/superposition/internal/github"
+)
+
+// HandlePullRequest pushes the session branch to its GitHub host (github.com
+// or a GitHub Enterprise Server) and opens a pull request against the
+// session's source branch. The optional body may set "title", "body" and
+// "draft"; by default the title is the branch name and the body summarises
+// the diff. Calling it again pushes new commits, or the branch rewritten by a
+// rebase, and returns the pull request if it is still open.
+func (h *SessionsHandler) HandlePullRequest(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var body struct {
+		Title string `json:"title"`
+		Body  string `json:"body"`
+		Draft bool   `json:"draft"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			WriteError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+
+	var worktreePath, branch, sourceBranch, baseCommit string
+	var repoID int64
+	var repoType, hostName, owner, name, localPath string
+	var prNumber sql.NullInt64
+	err := h.db.QueryRow(`SELECT s.worktree_path, s.branch, s.source_branch, s.base_commit, s.repo_id, s.pr_number,
+		r.repo_type, r.host, r.owner, r.name, r.local_path FROM sessions s JOIN repositories r ON s.repo_id = r.id WHERE s.id = ?`, id).
+		Scan(&worktreePath, &branch, &sourceBranch, &baseCommit, &repoID, &prNumber,
+			&repoType, &hostName, &owner, &name, &localPath)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if repoType != "github" {
+		WriteError(w, http.StatusBadRequest, "pull requests are only supported for repositories on GitHub hosts")
+		return
+	}
+	host, err := loadHost(h.db, hostName)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if host.Token == "" {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("no token configured for %s", hostName))
+		return
+	}
+	if sourceBranch == "" {
+		WriteError(w, http.StatusBadRequest, "session has no source branch to open a pull request against")
+		return
+	}
+
+	if baseCommit == "" {
+		baseCommit = inferBaseCommit(h.db, worktreePath, sourceBranch, repoID)
+	}
+	var diff *git.DiffResult
+	if baseCommit != "" {
+		diff, err = git.DiffCommits(localPath, baseCommit, "refs/heads/"+branch)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if diff.Stats.FilesChanged == 0 {
+			WriteError(w, http.StatusBadRequest, "session branch has no committed changes")
+			return
+		}
+	}
+
+	if err := git.Push(localPath, branch, repoCredentials(h.db, repoID, hostName)); err != nil {
+		WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	client := githubClient(host)
+	if prNumber.Valid {
+		// A closed or merged pull request takes no new commits, so only an
+		// open one is reused; otherwise a new one is opened.
+		pr, err := client.GetPullRequest(owner, name, int(prNumber.Int64))
+		if err != nil {
+			WriteError(w, githubErrorStatus(err), err.Error())
+			return
+		}
+		if pr.State == "open" {
+			WriteJSON(w, http.StatusOK, pr)
+			return
+		}
+		h.db.Exec(`UPDATE sessions SET pr_number = NULL, pr_url = NULL WHERE id = ?`, id)
+	}
+
+	if body.Title == "" {
+		body.Title = branch
+	}
+	if body.Body == "" && diff != nil {
+		body.Body = pullRequestBody(diff)
+	}
+
+	pr, err := client.CreatePullRequest(owner, name, github.NewPullRequest{
+		Title: body.Title,
+		Head:  branch,
+		Base:  sourceBranch,
+		Body:  body.Body,
+		Draft: body.Draft,
+	})
+	if err != nil {
+		WriteError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	h.db.Exec(`UPDATE sessions SET pr_number = ?, pr_url = ? WHERE id = ?`, pr.Number, pr.HTMLURL, id)
+	log.Printf("Opened pull request #%d for session %s", pr.Number, id)
+	WriteJSON(w, http.StatusCreated, pr)
+}
+
+// pullRequestBody renders diff stats as a Markdown summary.
+func pullRequestBody(diff *git.DiffResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d file(s) changed, %d insertion(s), %d deletion(s)\n\n",
+		diff.Stats.FilesChanged, diff.Stats.Additions, diff.Stats.Deletions)
+	b.WriteString("| File | Status | + | - |\n|---|---|---|---|\n")
+	for _, f := range diff.Files {
+		path := f.Path
+		if path == "" {
+			path = f.OldPath
+		}
+		if f.Status == "renamed" && f.OldPath != "" {
+			path = f.OldPath + " → " + f.Path
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %d | %d |\n", path, f.Status, f.Additions, f.Deletions)
+	}
+	return b.String()
+}
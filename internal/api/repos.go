@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/peterje/superposition/internal/git"
@@ -17,46 +18,81 @@ import (
 )
 
 type ReposHandler struct {
-	db          *sql.DB
-	cachedRepos []github.Repo
-	cachedAt    time.Time
+	db     *sql.DB
+	syncer *RepoSyncer
+
+	cacheMu sync.Mutex
+	cache   map[string]repoCache // by host
+}
+
+type repoCache struct {
+	repos    []github.Repo
+	cachedAt time.Time
 }
 
-func NewReposHandler(db *sql.DB) *ReposHandler {
-	return &ReposHandler{db: db}
+func NewReposHandler(db *sql.DB, syncer *RepoSyncer) *ReposHandler {
+	return &ReposHandler{db: db, syncer: syncer, cache: make(map[string]repoCache)}
 }
 
 const repoCacheTTL = 5 * time.Minute
 
+// HandleGitHubRepos lists the repositories visible to the token of a GitHub
+// host: github.com, or the GitHub Enterprise Server named by ?host=. Hosts
+// using a GitHub App list the repositories of the app installation.
 func (h *ReposHandler) HandleGitHubRepos(w http.ResponseWriter, r *http.Request) {
-	pat := h.getPAT()
-	if pat == "" {
+	hostName := r.URL.Query().Get("host")
+	if hostName == "" {
+		hostName = defaultHost
+	}
+	host, err := loadHost(h.db, hostName)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if host.Kind != hostGitHub {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("%s is not a GitHub host", hostName))
+		return
+	}
+	if host.Token == "" && hostName == defaultHost {
 		WriteError(w, http.StatusBadRequest, "GitHub PAT not configured")
 		return
 	}
+	if host.Token == "" {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("no token configured for %s", hostName))
+		return
+	}
 
 	refresh := r.URL.Query().Get("refresh") == "true"
 
 	// Fetch and cache all repos if cache is empty, stale, or refresh requested
-	if h.cachedRepos == nil || refresh || time.Since(h.cachedAt) > repoCacheTTL {
-		repos, err := github.ListAllRepos(pat)
+	h.cacheMu.Lock()
+	cached, ok := h.cache[hostName]
+	h.cacheMu.Unlock()
+	if !ok || refresh || time.Since(cached.cachedAt) > repoCacheTTL {
+		list := githubClient(host).ListAllRepos
+		if host.AppID != 0 {
+			list = githubClient(host).ListInstallationRepos
+		}
+		repos, err := list()
 		if err != nil {
 			WriteError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		h.cachedRepos = repos
-		h.cachedAt = time.Now()
+		cached = repoCache{repos: repos, cachedAt: time.Now()}
+		h.cacheMu.Lock()
+		h.cache[hostName] = cached
+		h.cacheMu.Unlock()
 	}
 
 	// Filter by search query client-side
 	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
 	if query == "" {
-		WriteJSON(w, http.StatusOK, h.cachedRepos)
+		WriteJSON(w, http.StatusOK, cached.repos)
 		return
 	}
 
 	filtered := []github.Repo{}
-	for _, r := range h.cachedRepos {
+	for _, r := range cached.repos {
 		if strings.Contains(strings.ToLower(r.FullName), query) ||
 			strings.Contains(strings.ToLower(r.Description), query) {
 			filtered = append(filtered, r)
@@ -66,7 +102,7 @@ func (h *ReposHandler) HandleGitHubRepos(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *ReposHandler) HandleList(w http.ResponseWriter, _ *http.Request) {
-	rows, err := h.db.Query(`SELECT id, github_url, owner, name, local_path, clone_status, default_branch, last_synced, created_at, source_path, repo_type FROM repositories ORDER BY created_at DESC`)
+	rows, err := h.db.Query(`SELECT id, github_url, owner, name, local_path, clone_status, clone_error, default_branch, last_synced, created_at, source_path, repo_type, last_sync_error, host, clone_url FROM repositories ORDER BY created_at DESC`)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -77,11 +113,14 @@ func (h *ReposHandler) HandleList(w http.ResponseWriter, _ *http.Request) {
 	for rows.Next() {
 		var repo models.Repository
 		var githubURL sql.NullString
-		if err := rows.Scan(&repo.ID, &githubURL, &repo.Owner, &repo.Name, &repo.LocalPath, &repo.CloneStatus, &repo.DefaultBranch, &repo.LastSynced, &repo.CreatedAt, &repo.SourcePath, &repo.RepoType); err != nil {
+		if err := rows.Scan(&repo.ID, &githubURL, &repo.Owner, &repo.Name, &repo.LocalPath, &repo.CloneStatus, &repo.CloneError, &repo.DefaultBranch, &repo.LastSynced, &repo.CreatedAt, &repo.SourcePath, &repo.RepoType, &repo.LastSyncError, &repo.Host, &repo.CloneURL); err != nil {
 			WriteError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 		repo.GitHubURL = githubURL.String
+		if repo.CloneStatus == "ready" {
+			repo.Syncing, repo.NextSync = h.syncer.State(repo.ID, repo.LastSynced)
+		}
 		repos = append(repos, repo)
 	}
 	WriteJSON(w, http.StatusOK, repos)
@@ -89,35 +128,48 @@ func (h *ReposHandler) HandleList(w http.ResponseWriter, _ *http.Request) {
 
 func (h *ReposHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		GitHubURL string `json:"github_url"`
+		URL       string `json:"url"`
+		GitHubURL string `json:"github_url"` // older name for url
 		LocalPath string `json:"local_path"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid JSON")
 		return
 	}
+	if body.URL == "" {
+		body.URL = body.GitHubURL
+	}
 
 	if body.LocalPath != "" {
 		h.createLocalRepo(w, body.LocalPath)
-	} else if body.GitHubURL != "" {
-		h.createGitHubRepo(w, body.GitHubURL)
+	} else if body.URL != "" {
+		h.createRemoteRepo(w, body.URL)
 	} else {
-		WriteError(w, http.StatusBadRequest, "github_url or local_path is required")
+		WriteError(w, http.StatusBadRequest, "url or local_path is required")
 	}
 }
 
-func (h *ReposHandler) createGitHubRepo(w http.ResponseWriter, githubURL string) {
-	owner, name, err := parseGitHubURL(githubURL)
+// createRemoteRepo adds a repository cloned from a git host. Repositories on
+// github hosts get repo_type "github"; everything else is "remote".
+func (h *ReposHandler) createRemoteRepo(w http.ResponseWriter, rawURL string) {
+	remote, err := parseRepoURL(rawURL)
 	if err != nil {
 		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-
-	cloneURL := fmt.Sprintf("https://github.com/%s/%s.git", owner, name)
+	host, err := loadHost(h.db, remote.Host)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	repoType := "remote"
+	if host.Kind == hostGitHub {
+		repoType = "github"
+	}
 
 	result, err := h.db.Exec(
-		`INSERT INTO repositories (github_url, owner, name, local_path, clone_status, default_branch, repo_type) VALUES (?, ?, ?, '', 'cloning', 'main', 'github')`,
-		githubURL, owner, name,
+		`INSERT INTO repositories (github_url, owner, name, local_path, clone_status, default_branch, repo_type, host, clone_url) VALUES (?, ?, ?, '', 'cloning', 'main', ?, ?, ?)`,
+		rawURL, remote.Owner, remote.Name, repoType, remote.Host, remote.CloneURL,
 	)
 	if err != nil {
 		if strings.Contains(err.Error(), "UNIQUE") {
@@ -129,15 +181,18 @@ func (h *ReposHandler) createGitHubRepo(w http.ResponseWriter, githubURL string)
 	}
 
 	id, _ := result.LastInsertId()
-	go h.cloneRepo(id, cloneURL, owner, name)
+	h.syncer.progress.publish(id, repoProgress{Status: "cloning"})
+	go h.cloneRepo(id, remote)
 
 	repo := models.Repository{
 		ID:          id,
-		GitHubURL:   githubURL,
-		Owner:       owner,
-		Name:        name,
+		GitHubURL:   rawURL,
+		Owner:       remote.Owner,
+		Name:        remote.Name,
 		CloneStatus: "cloning",
-		RepoType:    "github",
+		RepoType:    repoType,
+		Host:        remote.Host,
+		CloneURL:    remote.CloneURL,
 	}
 	WriteJSON(w, http.StatusCreated, repo)
 }
@@ -163,6 +218,7 @@ func (h *ReposHandler) createLocalRepo(w http.ResponseWriter, sourcePath string)
 	}
 
 	id, _ := result.LastInsertId()
+	h.syncer.progress.publish(id, repoProgress{Status: "cloning"})
 	go h.cloneLocalRepo(id, sourcePath, name)
 
 	repo := models.Repository{
@@ -201,6 +257,9 @@ func (h *ReposHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusNotFound, "repository not found")
 		return
 	}
+	if err := git.RemoveDeployKey(id); err != nil {
+		log.Printf("Delete repo id=%d: %v", id, err)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -214,8 +273,8 @@ func (h *ReposHandler) HandleSync(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Sync requested for repo id=%d", id)
 
 	var repo models.Repository
-	err = h.db.QueryRow(`SELECT id, local_path, clone_status, repo_type FROM repositories WHERE id = ?`, id).
-		Scan(&repo.ID, &repo.LocalPath, &repo.CloneStatus, &repo.RepoType)
+	err = h.db.QueryRow(`SELECT id, local_path, clone_status, host FROM repositories WHERE id = ?`, id).
+		Scan(&repo.ID, &repo.LocalPath, &repo.CloneStatus, &repo.Host)
 	if err == sql.ErrNoRows {
 		WriteError(w, http.StatusNotFound, "repository not found")
 		return
@@ -225,6 +284,10 @@ func (h *ReposHandler) HandleSync(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusInternalServerError, "database error")
 		return
 	}
+	if repo.CloneStatus == "error" {
+		h.retryClone(w, id)
+		return
+	}
 	if repo.CloneStatus != "ready" {
 		log.Printf("Sync repo id=%d: not ready (status=%s)", id, repo.CloneStatus)
 		WriteError(w, http.StatusBadRequest, "repository not ready")
@@ -232,20 +295,15 @@ func (h *ReposHandler) HandleSync(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Sync repo id=%d: fetching (path=%s)", id, repo.LocalPath)
-	pat := ""
-	if repo.RepoType != "local" {
-		pat = h.getPAT()
-	}
-	if err := git.Fetch(repo.LocalPath, pat); err != nil {
+	if err := h.syncer.Sync(id, repo.LocalPath, repo.Host); err != nil {
+		if err == ErrSyncInProgress {
+			WriteError(w, http.StatusConflict, err.Error())
+			return
+		}
 		log.Printf("Sync repo id=%d: git fetch failed: %v", id, err)
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-
-	now := time.Now()
-	if _, err := h.db.Exec(`UPDATE repositories SET last_synced = ? WHERE id = ?`, now, id); err != nil {
-		log.Printf("Sync repo id=%d: failed to update last_synced: %v", id, err)
-	}
 	log.Printf("Sync repo id=%d: complete", id)
 	WriteJSON(w, http.StatusOK, map[string]string{"status": "synced"})
 }
@@ -277,64 +335,140 @@ func (h *ReposHandler) HandleBranches(w http.ResponseWriter, r *http.Request) {
 	WriteJSON(w, http.StatusOK, branches)
 }
 
-func (h *ReposHandler) cloneRepo(id int64, cloneURL, owner, name string) {
-	pat := h.getPAT()
-	localPath, err := git.CloneBare(cloneURL, pat, owner, name)
+// HandleRefs lists the repository's branches, remote-tracking branches and
+// tags with their latest commit, and how far each branch is ahead of and
+// behind the default branch.
+func (h *ReposHandler) HandleRefs(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		log.Printf("Clone failed for %s/%s: %v", owner, name, err)
-		h.db.Exec(`UPDATE repositories SET clone_status = 'error' WHERE id = ?`, id)
+		WriteError(w, http.StatusBadRequest, "invalid id")
 		return
 	}
 
-	// Get default branch
-	defaultBranch := "main"
-	branches, err := git.ListBranches(localPath)
-	if err == nil && len(branches) > 0 {
-		defaultBranch = branches[0]
+	var localPath, cloneStatus, defaultBranch string
+	err = h.db.QueryRow(`SELECT local_path, clone_status, default_branch FROM repositories WHERE id = ?`, id).
+		Scan(&localPath, &cloneStatus, &defaultBranch)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "repository not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if cloneStatus != "ready" {
+		WriteError(w, http.StatusBadRequest, "repository not ready")
+		return
+	}
+
+	refs, err := git.ListRefs(localPath, defaultBranch)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteJSON(w, http.StatusOK, refs)
+}
+
+func (h *ReposHandler) cloneRepo(id int64, remote remoteRepo) {
+	// github.com repos keep their original <owner>/<name>.git location.
+	dir := remote.Owner
+	if remote.Host != defaultHost {
+		dir = remote.Host + "/" + remote.Owner
+	}
+	creds := repoCredentials(h.db, id, remote.Host)
+	localPath, err := git.CloneBare(remote.CloneURL, creds, dir, remote.Name, h.syncer.progress.reporter(id, "cloning"))
+	if err != nil {
+		log.Printf("Clone failed for %s/%s/%s: %v", remote.Host, remote.Owner, remote.Name, err)
+		h.cloneFailed(id, err)
+		return
 	}
 
+	defaultBranch := h.resolveDefaultBranch(localPath, &remote)
+
 	now := time.Now()
 	h.db.Exec(`UPDATE repositories SET local_path = ?, clone_status = 'ready', default_branch = ?, last_synced = ? WHERE id = ?`,
 		localPath, defaultBranch, now, id)
-	log.Printf("Cloned %s/%s to %s", owner, name, localPath)
+	h.syncer.progress.publish(id, repoProgress{Status: "ready", Percent: 100})
+	log.Printf("Cloned %s/%s/%s to %s", remote.Host, remote.Owner, remote.Name, localPath)
 }
 
 func (h *ReposHandler) cloneLocalRepo(id int64, sourcePath, name string) {
-	localPath, err := git.CloneBareLocal(sourcePath, name)
+	localPath, err := git.CloneBareLocal(sourcePath, name, h.syncer.progress.reporter(id, "cloning"))
 	if err != nil {
 		log.Printf("Clone failed for local repo %s: %v", sourcePath, err)
-		h.db.Exec(`UPDATE repositories SET clone_status = 'error' WHERE id = ?`, id)
+		h.cloneFailed(id, err)
 		return
 	}
 
-	defaultBranch := "main"
-	branches, err := git.ListBranches(localPath)
-	if err == nil && len(branches) > 0 {
-		defaultBranch = branches[0]
-	}
+	defaultBranch := h.resolveDefaultBranch(localPath, nil)
 
 	now := time.Now()
 	h.db.Exec(`UPDATE repositories SET local_path = ?, clone_status = 'ready', default_branch = ?, last_synced = ? WHERE id = ?`,
 		localPath, defaultBranch, now, id)
+	h.syncer.progress.publish(id, repoProgress{Status: "ready", Percent: 100})
 	log.Printf("Cloned local repo %s to %s", sourcePath, localPath)
 }
 
-func (h *ReposHandler) getPAT() string {
-	var pat string
-	h.db.QueryRow(`SELECT value FROM settings WHERE key = 'github_pat'`).Scan(&pat)
-	return pat
+// resolveDefaultBranch picks the default branch of a fresh clone: the GitHub
+// API's default_branch for repos on github hosts, otherwise the branch the
+// remote's HEAD points to, falling back to the first branch.
+func (h *ReposHandler) resolveDefaultBranch(localPath string, remote *remoteRepo) string {
+	if remote != nil {
+		if host, err := loadHost(h.db, remote.Host); err == nil && host.Kind == hostGitHub && host.Token != "" {
+			repo, err := githubClient(host).GetRepo(remote.Owner, remote.Name)
+			if err == nil && repo.DefaultBranch != "" {
+				if _, err := git.ResolveCommit(localPath, "refs/heads/"+repo.DefaultBranch); err == nil {
+					return repo.DefaultBranch
+				}
+			}
+		}
+	}
+	if branch := git.DefaultBranch(localPath); branch != "" {
+		return branch
+	}
+	if branches, err := git.ListBranches(localPath); err == nil && len(branches) > 0 {
+		return branches[0]
+	}
+	return "main"
 }
 
-func parseGitHubURL(rawURL string) (string, string, error) {
-	// Handle formats: https://github.com/owner/name, github.com/owner/name, owner/name
-	rawURL = strings.TrimSuffix(rawURL, ".git")
-	rawURL = strings.TrimPrefix(rawURL, "https://")
-	rawURL = strings.TrimPrefix(rawURL, "http://")
-	rawURL = strings.TrimPrefix(rawURL, "github.com/")
+// retryClone starts the clone of a repository whose clone failed, e.g. after
+// its token or deploy key was fixed.
+func (h *ReposHandler) retryClone(w http.ResponseWriter, id int64) {
+	var repoType, owner, name string
+	var remote remoteRepo
+	var sourcePath sql.NullString
+	err := h.db.QueryRow(`SELECT repo_type, owner, name, host, clone_url, source_path FROM repositories WHERE id = ?`, id).
+		Scan(&repoType, &owner, &name, &remote.Host, &remote.CloneURL, &sourcePath)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if repoType != "local" && remote.CloneURL == "" {
+		WriteError(w, http.StatusBadRequest, "repository has no clone URL")
+		return
+	}
 
-	parts := strings.Split(rawURL, "/")
-	if len(parts) < 2 {
-		return "", "", fmt.Errorf("invalid GitHub URL: need owner/name format")
+	h.db.Exec(`UPDATE repositories SET clone_status = 'cloning', clone_error = '' WHERE id = ?`, id)
+	h.syncer.progress.publish(id, repoProgress{Status: "cloning"})
+	log.Printf("Sync repo id=%d: retrying clone", id)
+	if repoType == "local" {
+		go h.cloneLocalRepo(id, sourcePath.String, name)
+	} else {
+		remote.Owner, remote.Name = owner, name
+		go h.cloneRepo(id, remote)
 	}
-	return parts[len(parts)-2], parts[len(parts)-1], nil
+	WriteJSON(w, http.StatusAccepted, map[string]string{"status": "cloning"})
+}
+
+// cloneFailed marks the repo as failed and records why.
+func (h *ReposHandler) cloneFailed(id int64, err error) {
+	h.db.Exec(`UPDATE repositories SET clone_status = 'error', clone_error = ? WHERE id = ?`, err.Error(), id)
+	h.syncer.progress.publish(id, repoProgress{Status: "error", Error: err.Error()})
+}
+
+func githubPAT(db *sql.DB) string {
+	var pat string
+	db.QueryRow(`SELECT value FROM settings WHERE key = 'github_pat'`).Scan(&pat)
+	return pat
 }
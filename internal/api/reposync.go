@@ -0,0 +1,204 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+// Sync intervals are settings in minutes: syncIntervalKey applies to every
+// repo and syncIntervalKey+".<repo id>" overrides it for one. 0 disables
+// background syncing.
+const (
+	syncIntervalKey     = "sync.interval_minutes"
+	defaultSyncInterval = 15 * time.Minute
+	syncCheckInterval   = time.Minute
+)
+
+// ErrSyncInProgress is returned by RepoSyncer.Sync when the repo is already
+// being fetched.
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// RepoSyncer fetches ready repositories in the background on their configured
+// interval. Manual syncs go through the same RepoSyncer so a repo is never
+// fetched twice at once.
+type RepoSyncer struct {
+	db       *sql.DB
+	progress *progressHub
+
+	mu          sync.Mutex
+	syncing     map[int64]bool
+	lastAttempt map[int64]time.Time
+}
+
+func NewRepoSyncer(db *sql.DB) *RepoSyncer {
+	return &RepoSyncer{
+		db:          db,
+		progress:    newProgressHub(),
+		syncing:     make(map[int64]bool),
+		lastAttempt: make(map[int64]time.Time),
+	}
+}
+
+// Run checks for repos due a sync every minute until ctx is cancelled.
+func (s *RepoSyncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(syncCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.syncDue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type syncTarget struct {
+	id         int64
+	localPath  string
+	host       string
+	lastSynced *time.Time
+}
+
+func (s *RepoSyncer) syncDue() {
+	rows, err := s.db.Query(`SELECT id, local_path, host, last_synced FROM repositories WHERE clone_status = 'ready'`)
+	if err != nil {
+		log.Printf("Scheduled sync: db query failed: %v", err)
+		return
+	}
+	var targets []syncTarget
+	for rows.Next() {
+		var t syncTarget
+		if err := rows.Scan(&t.id, &t.localPath, &t.host, &t.lastSynced); err != nil {
+			log.Printf("Scheduled sync: db scan failed: %v", err)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+
+	now := time.Now()
+	for _, t := range targets {
+		next := s.nextSync(t.id, t.lastSynced)
+		if next == nil || next.After(now) {
+			continue
+		}
+		go func(t syncTarget) {
+			if err := s.Sync(t.id, t.localPath, t.host); err != nil && err != ErrSyncInProgress {
+				log.Printf("Scheduled sync repo id=%d failed: %v", t.id, err)
+			}
+		}(t)
+	}
+}
+
+// Sync fetches the repo's bare clone and records the outcome in last_synced
+// and last_sync_error. It returns ErrSyncInProgress if the repo is already
+// being fetched. host is the repo's git host, or empty for local repos.
+func (s *RepoSyncer) Sync(id int64, localPath, host string) error {
+	s.mu.Lock()
+	if s.syncing[id] {
+		s.mu.Unlock()
+		return ErrSyncInProgress
+	}
+	s.syncing[id] = true
+	s.lastAttempt[id] = time.Now()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.syncing, id)
+		s.mu.Unlock()
+	}()
+
+	s.progress.publish(id, repoProgress{Status: "syncing"})
+	if err := git.FetchWithProgress(localPath, repoCredentials(s.db, id, host), sessionBranches(s.db, id), s.progress.reporter(id, "syncing")); err != nil {
+		if _, dbErr := s.db.Exec(`UPDATE repositories SET last_sync_error = ? WHERE id = ?`, err.Error(), id); dbErr != nil {
+			log.Printf("Sync repo id=%d: failed to record error: %v", id, dbErr)
+		}
+		s.progress.publish(id, repoProgress{Status: "error", Error: err.Error()})
+		return err
+	}
+
+	// Follow the remote if its default branch changed. A local repo's HEAD is
+	// just whatever its folder has checked out, so those keep theirs.
+	if branch := git.DefaultBranch(localPath); branch != "" && host != "" {
+		s.db.Exec(`UPDATE repositories SET default_branch = ? WHERE id = ?`, branch, id)
+	}
+	if _, err := s.db.Exec(`UPDATE repositories SET last_synced = ?, last_sync_error = '' WHERE id = ?`, time.Now(), id); err != nil {
+		log.Printf("Sync repo id=%d: failed to update last_synced: %v", id, err)
+	}
+	s.progress.publish(id, repoProgress{Status: "ready", Percent: 100})
+	return nil
+}
+
+// State reports whether the repo is being fetched now and when it is next due,
+// or nil if background syncing is disabled for it.
+func (s *RepoSyncer) State(id int64, lastSynced *time.Time) (bool, *time.Time) {
+	s.mu.Lock()
+	syncing := s.syncing[id]
+	s.mu.Unlock()
+	return syncing, s.nextSync(id, lastSynced)
+}
+
+// nextSync is one interval after the later of the last successful sync and
+// the last attempt, so failing repos are retried on the same schedule.
+func (s *RepoSyncer) nextSync(id int64, lastSynced *time.Time) *time.Time {
+	interval := s.interval(id)
+	if interval <= 0 {
+		return nil
+	}
+	var last time.Time
+	if lastSynced != nil {
+		last = *lastSynced
+	}
+	s.mu.Lock()
+	if t := s.lastAttempt[id]; t.After(last) {
+		last = t
+	}
+	s.mu.Unlock()
+	next := last.Add(interval)
+	return &next
+}
+
+// interval returns the repo's sync interval, preferring its own setting over
+// the global one. Zero means disabled.
+func (s *RepoSyncer) interval(id int64) time.Duration {
+	for _, key := range []string{syncIntervalKey + "." + strconv.FormatInt(id, 10), syncIntervalKey} {
+		var val string
+		if err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&val); err != nil {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || n < 0 {
+			continue
+		}
+		return time.Duration(n) * time.Minute
+	}
+	return defaultSyncInterval
+}
+
+// sessionBranches returns the branches of a repository's sessions, which a
+// fetch must not move: their worktrees may be gone while the branch still
+// holds the session's work.
+func sessionBranches(db *sql.DB, repoID int64) map[string]bool {
+	rows, err := db.Query(`SELECT branch FROM sessions WHERE repo_id = ?`, repoID)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	branches := make(map[string]bool)
+	for rows.Next() {
+		var branch string
+		if rows.Scan(&branch) == nil {
+			branches[branch] = true
+		}
+	}
+	return branches
+}
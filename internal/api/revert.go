@@ -0,0 +1,82 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/peterje/superposition/internal/git"
+)
+
+// HandleRevert discards a change from the session worktree, restoring it to
+// the base commit. The body names a file path from the diff and, optionally,
+// the index of one of its hunks; without a hunk the whole file is reverted.
+func (h *SessionsHandler) HandleRevert(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var body struct {
+		Path string `json:"path"`
+		Hunk *int   `json:"hunk"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	path, err := cleanRelPath(body.Path)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	worktreePath, baseCommit, err := loadSessionBase(h.db, id)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if baseCommit == "" {
+		WriteError(w, http.StatusConflict, "session has no base commit to revert to")
+		return
+	}
+
+	diff, err := git.DiffPath(worktreePath, baseCommit, git.DiffAll, path)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	file := diff.File(path)
+	if file == nil {
+		WriteError(w, http.StatusNotFound, "file not in diff")
+		return
+	}
+
+	// Untracked files aren't part of git's diff, so they can only go whole.
+	if body.Hunk == nil || file.Location == git.LocationUntracked {
+		oldPath := ""
+		if file.Status == "renamed" {
+			oldPath = file.OldPath
+		}
+		err = git.RevertFile(worktreePath, baseCommit, path, oldPath)
+	} else {
+		if *body.Hunk < 0 || *body.Hunk >= len(file.Hunks) {
+			WriteError(w, http.StatusBadRequest, "hunk index out of range")
+			return
+		}
+		err = git.RevertHunk(worktreePath, baseCommit, path, file.OldPath, *body.Hunk)
+	}
+	if err != nil {
+		WriteError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if body.Hunk != nil {
+		log.Printf("Session %s: reverted hunk %d of %s", id, *body.Hunk, path)
+	} else {
+		log.Printf("Session %s: reverted %s", id, path)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,17 +3,25 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/peterje/superposition/internal/agents"
 	"github.com/peterje/superposition/internal/git"
+	"github.com/peterje/superposition/internal/github"
 	"github.com/peterje/superposition/internal/models"
 	ptymgr "github.com/peterje/superposition/internal/pty"
+	"github.com/peterje/superposition/internal/transcript"
 )
 
 type SessionsHandler struct {
@@ -27,7 +35,7 @@ func NewSessionsHandler(db *sql.DB, manager ptymgr.SessionManager) *SessionsHand
 
 func (h *SessionsHandler) HandleList(w http.ResponseWriter, _ *http.Request) {
 	rows, err := h.db.Query(`SELECT s.id, s.repo_id, s.worktree_path, s.branch, s.cli_type, s.status, s.pid, s.created_at,
-		s.source_branch, s.base_commit, r.owner, r.name FROM sessions s JOIN repositories r ON s.repo_id = r.id ORDER BY s.created_at DESC`)
+		s.source_branch, s.base_commit, s.resumable, s.pr_number, s.pr_url, s.issue_number, s.issue_url, r.owner, r.name, r.repo_type FROM sessions s JOIN repositories r ON s.repo_id = r.id ORDER BY s.created_at DESC`)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -38,13 +46,14 @@ func (h *SessionsHandler) HandleList(w http.ResponseWriter, _ *http.Request) {
 		models.Session
 		RepoOwner string `json:"repo_owner"`
 		RepoName  string `json:"repo_name"`
+		RepoType  string `json:"repo_type"`
 	}
 
 	sessions := []sessionWithRepo{}
 	for rows.Next() {
 		var s sessionWithRepo
 		if err := rows.Scan(&s.ID, &s.RepoID, &s.WorktreePath, &s.Branch, &s.CLIType, &s.Status, &s.PID, &s.CreatedAt,
-			&s.SourceBranch, &s.BaseCommit, &s.RepoOwner, &s.RepoName); err != nil {
+			&s.SourceBranch, &s.BaseCommit, &s.Resumable, &s.PRNumber, &s.PRURL, &s.IssueNumber, &s.IssueURL, &s.RepoOwner, &s.RepoName, &s.RepoType); err != nil {
 			WriteError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
@@ -53,35 +62,53 @@ func (h *SessionsHandler) HandleList(w http.ResponseWriter, _ *http.Request) {
 	WriteJSON(w, http.StatusOK, sessions)
 }
 
+// HandleCreate starts a session in a new worktree. An optional "prompt" is
+// handed to the agent as its first instruction, the way the agent's prompt
+// template says. With "issue" the session works on a GitHub issue: its
+// branch is named after the issue unless new_branch is given, and the issue
+// text is sent ahead of any prompt. With "pull_request" the pull request's
+// head branch is checked out instead of creating a new branch, so pushes
+// from the session update it.
 func (h *SessionsHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		RepoID       int64  `json:"repo_id"`
-		SourceBranch string `json:"source_branch"`
-		NewBranch    string `json:"new_branch"`
-		CLIType      string `json:"cli_type"`
+		RepoID       int64             `json:"repo_id"`
+		SourceBranch string            `json:"source_branch"`
+		NewBranch    string            `json:"new_branch"`
+		CLIType      string            `json:"cli_type"`
+		Env          map[string]string `json:"env"`
+		Resumable    *bool             `json:"resumable"`
+		Issue        int               `json:"issue"`
+		PullRequest  int               `json:"pull_request"`
+		Prompt       string            `json:"prompt"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		WriteError(w, http.StatusBadRequest, "invalid JSON")
 		return
 	}
 
-	if body.CLIType != "claude" && body.CLIType != "codex" && body.CLIType != "gemini" {
-		WriteError(w, http.StatusBadRequest, "cli_type must be 'claude', 'codex', or 'gemini'")
+	agent, ok := agents.Lookup(h.db, body.CLIType)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("unknown cli_type %q", body.CLIType))
 		return
 	}
-	if body.SourceBranch == "" {
-		WriteError(w, http.StatusBadRequest, "source_branch is required")
+	if body.Issue != 0 && body.PullRequest != 0 {
+		WriteError(w, http.StatusBadRequest, "issue and pull_request can't both be set")
 		return
 	}
-	if body.NewBranch == "" {
-		WriteError(w, http.StatusBadRequest, "new_branch is required")
-		return
+	if body.Env == nil {
+		body.Env = map[string]string{}
+	}
+	for name := range body.Env {
+		if err := validateEnvName(name); err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
 
 	// Get repo info
 	var repo models.Repository
-	err := h.db.QueryRow(`SELECT id, local_path, clone_status FROM repositories WHERE id = ?`, body.RepoID).
-		Scan(&repo.ID, &repo.LocalPath, &repo.CloneStatus)
+	err := h.db.QueryRow(`SELECT id, local_path, clone_status, repo_type, host, owner, name, default_branch FROM repositories WHERE id = ?`, body.RepoID).
+		Scan(&repo.ID, &repo.LocalPath, &repo.CloneStatus, &repo.RepoType, &repo.Host, &repo.Owner, &repo.Name, &repo.DefaultBranch)
 	if err == sql.ErrNoRows {
 		WriteError(w, http.StatusNotFound, "repository not found")
 		return
@@ -91,47 +118,108 @@ func (h *SessionsHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create worktree
+	var issue *github.Issue
+	var pr *github.PullRequest
+	if body.Issue != 0 || body.PullRequest != 0 {
+		client, status, err := repoGitHubClient(h.db, repo)
+		if err != nil {
+			WriteError(w, status, err.Error())
+			return
+		}
+		if body.Issue != 0 {
+			issue, err = client.GetIssue(repo.Owner, repo.Name, body.Issue)
+			if err != nil {
+				WriteError(w, githubErrorStatus(err), err.Error())
+				return
+			}
+			if issue.PullRequest != nil {
+				WriteError(w, http.StatusBadRequest, fmt.Sprintf("#%d is a pull request; use pull_request", body.Issue))
+				return
+			}
+			if body.NewBranch == "" {
+				body.NewBranch = issueBranch(issue)
+			}
+			if body.SourceBranch == "" {
+				body.SourceBranch = repo.DefaultBranch
+			}
+		} else {
+			pr, err = client.GetPullRequest(repo.Owner, repo.Name, body.PullRequest)
+			if err != nil {
+				WriteError(w, githubErrorStatus(err), err.Error())
+				return
+			}
+			if pr.Head == nil || pr.Base == nil || pr.Head.Repo == nil || !strings.EqualFold(pr.Head.Repo.FullName, repo.Owner+"/"+repo.Name) {
+				WriteError(w, http.StatusBadRequest, fmt.Sprintf("pull request #%d is from a fork; only pull requests from branches of %s/%s can be checked out", pr.Number, repo.Owner, repo.Name))
+				return
+			}
+			body.SourceBranch, body.NewBranch = pr.Base.Ref, pr.Head.Ref
+		}
+	}
+	if body.SourceBranch == "" {
+		WriteError(w, http.StatusBadRequest, "source_branch is required")
+		return
+	}
+	if body.NewBranch == "" {
+		WriteError(w, http.StatusBadRequest, "new_branch is required")
+		return
+	}
+
 	sessionID := uuid.New().String()[:8]
-	wtDir, err := git.WorktreesDir()
+	env, err := buildSessionEnv(h.db, agent, sessionID, repo.ID, body.Env)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	worktreePath := filepath.Join(wtDir, sessionID)
+	sessionEnv, _ := json.Marshal(body.Env)
+	resumable := body.Resumable == nil || *body.Resumable
 
-	if err := git.AddWorktree(repo.LocalPath, worktreePath, body.NewBranch, body.SourceBranch); err != nil {
-		WriteError(w, http.StatusInternalServerError, fmt.Sprintf("create worktree: %v", err))
+	// Create worktree
+	wtDir, err := git.WorktreesDir()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	worktreePath := filepath.Join(wtDir, sessionID)
 
-	// Resolve the base commit SHA for diff support
-	baseCommit, _ := git.ResolveCommit(worktreePath, "HEAD")
+	var baseCommit string
+	if pr != nil {
+		// Fetch so the local branch matches the pull request's latest head.
+		if err := git.Fetch(repo.LocalPath, repoCredentials(h.db, repo.ID, repo.Host), sessionBranches(h.db, repo.ID)); err != nil {
+			WriteError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+		if err := git.RestoreWorktree(repo.LocalPath, worktreePath, body.NewBranch); err != nil {
+			WriteError(w, http.StatusConflict, fmt.Sprintf("check out pull request branch: %v", err))
+			return
+		}
+		baseCommit = inferBaseCommit(h.db, worktreePath, body.SourceBranch, repo.ID)
+	} else {
+		if err := git.AddWorktree(repo.LocalPath, worktreePath, body.NewBranch, body.SourceBranch); err != nil {
+			WriteError(w, http.StatusInternalServerError, fmt.Sprintf("create worktree: %v", err))
+			return
+		}
+		// Resolve the base commit SHA for diff support
+		baseCommit, _ = git.ResolveCommit(worktreePath, "HEAD")
+	}
 
-	// Resolve CLI command (may include args from settings override)
-	command := resolveCommand(h.db, body.CLIType)
+	prompt := strings.TrimSpace(body.Prompt)
+	if issue != nil {
+		prompt = strings.TrimSpace(issuePrompt(issue) + "\n\n" + prompt)
+	}
+	command := agent.Command()
+	if prompt != "" {
+		command = agent.PromptCommand(prompt)
+	}
 
 	// Start PTY
-	sess, pid, err := h.manager.Start(sessionID, command, worktreePath)
+	sess, pid, err := h.manager.Start(sessionID, command, worktreePath, env, promptStdin(agent, prompt))
 	if err != nil {
 		git.RemoveWorktree(repo.LocalPath, worktreePath)
 		WriteError(w, http.StatusInternalServerError, fmt.Sprintf("start session: %v", err))
 		return
 	}
 
-	now := time.Now()
-	h.db.Exec(`INSERT INTO sessions (id, repo_id, worktree_path, branch, cli_type, status, pid, created_at, source_branch, base_commit)
-		VALUES (?, ?, ?, ?, ?, 'running', ?, ?, ?, ?)`,
-		sessionID, body.RepoID, worktreePath, body.NewBranch, body.CLIType, pid, now, body.SourceBranch, baseCommit)
-
-	// Monitor for process exit and update DB
-	go func() {
-		<-sess.Done()
-		h.db.Exec(`UPDATE sessions SET status = 'stopped' WHERE id = ?`, sessionID)
-		log.Printf("Session %s stopped", sessionID)
-	}()
-
-	WriteJSON(w, http.StatusCreated, models.Session{
+	session := models.Session{
 		ID:           sessionID,
 		RepoID:       body.RepoID,
 		WorktreePath: worktreePath,
@@ -139,10 +227,124 @@ func (h *SessionsHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
 		CLIType:      body.CLIType,
 		Status:       "running",
 		PID:          &pid,
-		CreatedAt:    now,
+		CreatedAt:    time.Now(),
 		SourceBranch: body.SourceBranch,
 		BaseCommit:   baseCommit,
-	})
+		Resumable:    resumable,
+	}
+	if issue != nil {
+		session.IssueNumber, session.IssueURL = &issue.Number, &issue.HTMLURL
+	}
+	if pr != nil {
+		session.PRNumber, session.PRURL = &pr.Number, &pr.HTMLURL
+	}
+
+	h.db.Exec(`INSERT INTO sessions (id, repo_id, worktree_path, branch, cli_type, status, pid, created_at, source_branch, base_commit, env, resumable,
+		issue_number, issue_url, pr_number, pr_url)
+		VALUES (?, ?, ?, ?, ?, 'running', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessionID, body.RepoID, worktreePath, body.NewBranch, body.CLIType, pid, session.CreatedAt, body.SourceBranch, baseCommit, string(sessionEnv), resumable,
+		session.IssueNumber, session.IssueURL, session.PRNumber, session.PRURL)
+
+	h.watchSession(sessionID, pid, sess)
+	if prompt != "" {
+		sendInitialPrompt(sess, agent, prompt)
+	}
+
+	WriteJSON(w, http.StatusCreated, session)
+}
+
+// watchSession marks the session stopped in the DB once its process exits.
+// The pid guard keeps a late exit from an earlier run from overwriting a
+// restarted session's status.
+func (h *SessionsHandler) watchSession(id string, pid int, sess ptymgr.SessionHandle) {
+	go func() {
+		<-sess.Done()
+		h.db.Exec(`UPDATE sessions SET status = 'stopped', stopped_at = CURRENT_TIMESTAMP WHERE id = ? AND pid = ?`, id, pid)
+		log.Printf("Session %s stopped", id)
+	}()
+}
+
+// HandleRestart relaunches the agent of a stopped session in its existing
+// worktree and branch. Unless {"resume": false} is sent, the agent's resume
+// args are added so it continues its previous conversation. If the worktree
+// was cleaned up it is re-created from the session branch.
+func (h *SessionsHandler) HandleRestart(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var body struct {
+		Resume *bool `json:"resume"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+			WriteError(w, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+	}
+	resume := body.Resume == nil || *body.Resume
+
+	var s models.Session
+	var envJSON, localPath string
+	err := h.db.QueryRow(`SELECT s.id, s.repo_id, s.worktree_path, s.branch, s.cli_type, s.created_at, s.source_branch, s.base_commit,
+		s.resumable, s.env, r.local_path FROM sessions s JOIN repositories r ON s.repo_id = r.id WHERE s.id = ?`, id).
+		Scan(&s.ID, &s.RepoID, &s.WorktreePath, &s.Branch, &s.CLIType, &s.CreatedAt, &s.SourceBranch, &s.BaseCommit,
+			&s.Resumable, &envJSON, &localPath)
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if running := h.manager.Get(id); running != nil {
+		select {
+		case <-running.Done():
+		default:
+			WriteError(w, http.StatusConflict, "session is still running")
+			return
+		}
+	}
+
+	agent, ok := agents.Lookup(h.db, s.CLIType)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, fmt.Sprintf("agent %q is no longer registered", s.CLIType))
+		return
+	}
+
+	if _, err := os.Stat(s.WorktreePath); errors.Is(err, fs.ErrNotExist) {
+		if err := git.RestoreWorktree(localPath, s.WorktreePath, s.Branch); err != nil {
+			WriteError(w, http.StatusConflict, fmt.Sprintf("worktree is gone and could not be restored: %v", err))
+			return
+		}
+		log.Printf("Restored worktree %s for session %s", s.WorktreePath, id)
+	}
+
+	var sessionEnv map[string]string
+	json.Unmarshal([]byte(envJSON), &sessionEnv)
+	env, err := buildSessionEnv(h.db, agent, id, s.RepoID, sessionEnv)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	command := agent.Command()
+	if resume {
+		command = agent.ResumeCommand()
+	}
+
+	sess, pid, err := h.manager.Start(id, command, s.WorktreePath, env, "")
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, fmt.Sprintf("start session: %v", err))
+		return
+	}
+
+	h.db.Exec(`UPDATE sessions SET status = 'running', pid = ? WHERE id = ?`, pid, id)
+	h.watchSession(id, pid, sess)
+
+	s.Status = "running"
+	s.PID = &pid
+	WriteJSON(w, http.StatusOK, s)
 }
 
 func (h *SessionsHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
@@ -158,6 +360,90 @@ func (h *SessionsHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
 	w.Write(replay)
 }
 
+// HandleTranscript serves the on-disk transcript of a session's PTY output.
+// It remains available after the session stops. Byte ranges are supported
+// through the standard Range header, and ?tail=N returns only the last N
+// bytes. X-Transcript-Start reports the absolute offset of the first byte
+// still retained after log rotation.
+func (h *SessionsHandler) HandleTranscript(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var exists int
+	if err := h.db.QueryRow(`SELECT 1 FROM sessions WHERE id = ?`, id).Scan(&exists); err != nil {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	rd, err := transcript.Open(id)
+	if errors.Is(err, fs.ErrNotExist) {
+		WriteError(w, http.StatusNotFound, "no transcript for session")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer rd.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Transcript-Start", strconv.FormatInt(rd.Start(), 10))
+
+	if raw := r.URL.Query().Get("tail"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			WriteError(w, http.StatusBadRequest, "tail must be a positive number of bytes")
+			return
+		}
+		n = min(n, rd.Size())
+		rd.Seek(-n, io.SeekEnd)
+		w.Header().Set("Content-Length", strconv.FormatInt(n, 10))
+		w.WriteHeader(http.StatusOK)
+		io.CopyN(w, rd, n)
+		return
+	}
+
+	http.ServeContent(w, r, "", time.Time{}, rd)
+}
+
+// HandleRecording serves a session's asciicast v2 recording for playback
+// with asciinema or attaching to a PR.
+func (h *SessionsHandler) HandleRecording(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var exists int
+	if err := h.db.QueryRow(`SELECT 1 FROM sessions WHERE id = ?`, id).Scan(&exists); err != nil {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	path, err := transcript.RecordingPath(id)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		WriteError(w, http.StatusNotFound, "no recording for session")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="session-%s.cast"`, id))
+	// The recording may still be growing; serve the size as of now.
+	http.ServeContent(w, r, "", info.ModTime(), io.NewSectionReader(f, 0, info.Size()))
+}
+
 func (h *SessionsHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	deleteLocal := true
@@ -204,7 +490,11 @@ func (h *SessionsHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Delete the session row
+	// Delete the session row and anything hanging off it
+	if err := transcript.Remove(id); err != nil {
+		log.Printf("Failed to remove transcript for %s: %v", id, err)
+	}
+	h.db.Exec(`DELETE FROM review_comments WHERE session_id = ?`, id)
 	h.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -233,13 +523,40 @@ func (h *SessionsHandler) HandleInput(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// maxLazyFileLines caps a single file fetched through HandleDiffFile.
+const maxLazyFileLines = 50000
+
+// HandleDiff returns the session diff. ?mode= selects committed, staged,
+// unstaged or all (the default) changes. ?summary=true returns only files
+// and stats, and ?offset=&limit= page through the file list. Generated files
+// and files over the diff.max_file_lines / diff.max_total_lines settings come
+// back with truncated set and no hunks; fetch them with HandleDiffFile.
 func (h *SessionsHandler) HandleDiff(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
+	q := r.URL.Query()
 
-	var worktreePath, baseCommit, sourceBranch string
-	var repoID int64
-	err := h.db.QueryRow(`SELECT worktree_path, base_commit, source_branch, repo_id FROM sessions WHERE id = ?`, id).
-		Scan(&worktreePath, &baseCommit, &sourceBranch, &repoID)
+	mode, err := git.ParseDiffMode(q.Get("mode"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	offset, err := queryInt(q.Get("offset"), 0)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(q.Get("limit"), 0)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+
+	summary := q.Get("summary") == "true"
+	diffFunc := git.DiffWithMode
+	if summary {
+		diffFunc = git.DiffSummary
+	}
+	diff, err := loadSessionDiff(h.db, id, mode, diffFunc)
 	if err == sql.ErrNoRows {
 		WriteError(w, http.StatusNotFound, "session not found")
 		return
@@ -249,23 +566,120 @@ func (h *SessionsHandler) HandleDiff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For sessions created before base_commit was tracked, try to compute it
-	if baseCommit == "" {
-		baseCommit = inferBaseCommit(h.db, worktreePath, sourceBranch, repoID)
-		if baseCommit == "" {
-			WriteJSON(w, http.StatusOK, git.DiffResult{Files: []git.DiffFile{}})
-			return
-		}
-		// Backfill so we don't recompute next time
-		h.db.Exec(`UPDATE sessions SET base_commit = ? WHERE id = ?`, baseCommit, id)
+	// Stats keep describing the whole diff; only the file list is paged.
+	if offset > len(diff.Files) {
+		offset = len(diff.Files)
+	}
+	diff.Files = diff.Files[offset:]
+	if limit > 0 && limit < len(diff.Files) {
+		diff.Files = diff.Files[:limit]
+	}
+
+	if !summary {
+		diff.ApplyLimits(git.DiffLimits{
+			MaxFileLines:  intSetting(h.db, "diff.max_file_lines", 1000),
+			MaxTotalLines: intSetting(h.db, "diff.max_total_lines", 10000),
+		})
+		diff.AddIntraline()
 	}
+	WriteJSON(w, http.StatusOK, diff)
+}
+
+// HandleDiffFile returns a single file from the session diff, including the
+// hunks HandleDiff left out for summary or truncated files.
+func (h *SessionsHandler) HandleDiffFile(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
 
-	diff, err := git.Diff(worktreePath, baseCommit)
+	mode, err := git.ParseDiffMode(q.Get("mode"))
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, fmt.Sprintf("diff: %v", err))
+		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	WriteJSON(w, http.StatusOK, diff)
+	path := q.Get("path")
+	if path == "" {
+		WriteError(w, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	diff, err := loadSessionDiff(h.db, id, mode, func(worktreePath, baseCommit string, mode git.DiffMode) (*git.DiffResult, error) {
+		return git.DiffPath(worktreePath, baseCommit, mode, path)
+	})
+	if err == sql.ErrNoRows {
+		WriteError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	file := diff.File(path)
+	if file == nil {
+		WriteError(w, http.StatusNotFound, "file not in diff")
+		return
+	}
+	file.TruncateFile(maxLazyFileLines)
+	file.AddIntraline()
+	WriteJSON(w, http.StatusOK, file)
+}
+
+// queryInt parses a non-negative integer query parameter.
+func queryInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// loadSessionDiff computes the current diff for a session in the given mode
+// with diffFunc, e.g. git.DiffWithMode or git.DiffSummary. It returns
+// sql.ErrNoRows if the session does not exist.
+func loadSessionDiff(db *sql.DB, id string, mode git.DiffMode, diffFunc func(string, string, git.DiffMode) (*git.DiffResult, error)) (*git.DiffResult, error) {
+	worktreePath, baseCommit, err := loadSessionBase(db, id)
+	if err != nil {
+		return nil, err
+	}
+	// Modes measured against the base commit need one.
+	if baseCommit == "" && (mode == git.DiffCommitted || mode == git.DiffAll) {
+		return &git.DiffResult{Files: []git.DiffFile{}}, nil
+	}
+
+	diff, err := diffFunc(worktreePath, baseCommit, mode)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	if err := git.MarkGenerated(worktreePath, diff); err != nil {
+		log.Printf("Session %s: %v", id, err)
+	}
+	return diff, nil
+}
+
+// loadSessionBase returns a session's worktree path and base commit. The base
+// commit is empty if it isn't recorded and can't be inferred. It returns
+// sql.ErrNoRows if the session does not exist.
+func loadSessionBase(db *sql.DB, id string) (string, string, error) {
+	var worktreePath, baseCommit, sourceBranch string
+	var repoID int64
+	err := db.QueryRow(`SELECT worktree_path, base_commit, source_branch, repo_id FROM sessions WHERE id = ?`, id).
+		Scan(&worktreePath, &baseCommit, &sourceBranch, &repoID)
+	if err != nil {
+		return "", "", err
+	}
+
+	// For sessions created before base_commit was tracked, try to compute it
+	if baseCommit == "" {
+		baseCommit = inferBaseCommit(db, worktreePath, sourceBranch, repoID)
+		if baseCommit != "" {
+			// Backfill so we don't recompute next time
+			db.Exec(`UPDATE sessions SET base_commit = ? WHERE id = ?`, baseCommit, id)
+		}
+	}
+	return worktreePath, baseCommit, nil
 }
 
 // inferBaseCommit tries to determine the base commit for a session that
@@ -294,14 +708,3 @@ func inferBaseCommit(db *sql.DB, worktreePath, sourceBranch string, repoID int64
 	}
 	return ""
 }
-
-// resolveCommand returns the override command string for a CLI type if one
-// exists in settings, otherwise returns the bare CLI type name.
-func resolveCommand(db *sql.DB, cliType string) string {
-	var val string
-	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, "cli_command."+cliType).Scan(&val)
-	if err == nil && val != "" {
-		return val
-	}
-	return cliType
-}
@@ -4,17 +4,22 @@ import (
 	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/peterje/superposition/internal/models"
 )
 
 type SettingsHandler struct {
-	db *sql.DB
+	db       *sql.DB
+	onChange func(key string)
 }
 
-func NewSettingsHandler(db *sql.DB) *SettingsHandler {
-	return &SettingsHandler{db: db}
+// NewSettingsHandler returns a handler for the settings endpoints. onChange,
+// if non-nil, is called with the key of every setting written or deleted.
+func NewSettingsHandler(db *sql.DB, onChange func(key string)) *SettingsHandler {
+	return &SettingsHandler{db: db, onChange: onChange}
 }
 
 func (h *SettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -51,7 +56,7 @@ func (h *SettingsHandler) listSettings(w http.ResponseWriter, _ *http.Request) {
 			WriteError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-		settings = append(settings, s)
+		settings = append(settings, maskSetting(s))
 	}
 	WriteJSON(w, http.StatusOK, settings)
 }
@@ -68,7 +73,7 @@ func (h *SettingsHandler) getSetting(w http.ResponseWriter, _ *http.Request, key
 		WriteError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	WriteJSON(w, http.StatusOK, s)
+	WriteJSON(w, http.StatusOK, maskSetting(s))
 }
 
 func (h *SettingsHandler) putSetting(w http.ResponseWriter, r *http.Request, key string) {
@@ -85,6 +90,23 @@ func (h *SettingsHandler) putSetting(w http.ResponseWriter, r *http.Request, key
 		return
 	}
 
+	if strings.HasPrefix(key, repoEnvPrefix) {
+		value, err := prepareEnvSetting(h.db, key, body.Value)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		body.Value = value
+	}
+	if strings.HasPrefix(key, hostPrefix) {
+		value, err := prepareHostSetting(h.db, key, body.Value)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		body.Value = value
+	}
+
 	now := time.Now()
 	_, err := h.db.Exec(
 		`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
@@ -96,7 +118,10 @@ func (h *SettingsHandler) putSetting(w http.ResponseWriter, r *http.Request, key
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, models.Setting{Key: key, Value: body.Value, UpdatedAt: now})
+	if h.onChange != nil {
+		h.onChange(key)
+	}
+	WriteJSON(w, http.StatusOK, maskSetting(models.Setting{Key: key, Value: body.Value, UpdatedAt: now}))
 }
 
 func (h *SettingsHandler) deleteSetting(w http.ResponseWriter, _ *http.Request, key string) {
@@ -115,9 +140,26 @@ func (h *SettingsHandler) deleteSetting(w http.ResponseWriter, _ *http.Request,
 		WriteError(w, http.StatusNotFound, "setting not found")
 		return
 	}
+	if h.onChange != nil {
+		h.onChange(key)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// intSetting reads a positive integer setting, falling back to def when it is
+// unset or invalid.
+func intSetting(db *sql.DB, key string, def int) int {
+	var val string
+	if err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&val); err != nil {
+		return def
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
 func WriteJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -1,10 +1,18 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
+	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -39,10 +47,209 @@ func Open() (*sql.DB, error) {
 	return db, nil
 }
 
-func Migrate(db *sql.DB, migrationsSQL string) error {
-	_, err := db.Exec(migrationsSQL)
+// migration is a single versioned schema change loaded from migrations/*.sql.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// Migrate applies every migration in fsys (matched by migrations/*.sql) that
+// has not yet been recorded in schema_migrations. Files must be named
+// NNN_description.sql; they run in version order, each exactly once and
+// inside its own transaction. Migrate refuses to run against a database that
+// has applied versions this binary doesn't know about.
+func Migrate(db *sql.DB, fsys fs.FS) error {
+	migrations, err := loadMigrations(fsys)
 	if err != nil {
-		return fmt.Errorf("run migrations: %w", err)
+		return err
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	if err := baselineLegacy(db); err != nil {
+		return fmt.Errorf("baseline legacy schema: %w", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("read schema_migrations: %w", err)
+	}
+	maxApplied := 0
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("read schema_migrations: %w", err)
+		}
+		applied[v] = true
+		if v > maxApplied {
+			maxApplied = v
+		}
+	}
+	rows.Close()
+
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+	if maxApplied > latest {
+		return fmt.Errorf("database schema version %d is newer than this binary supports (%d); upgrade superposition", maxApplied, latest)
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %03d_%s", m.version, m.name)
 	}
 	return nil
 }
+
+// loadMigrations reads and sorts the migration files in fsys.
+func loadMigrations(fsys fs.FS) ([]migration, error) {
+	paths, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		return nil, fmt.Errorf("list migrations: %w", err)
+	}
+
+	migrations := make([]migration, 0, len(paths))
+	seen := make(map[int]string)
+	for _, p := range paths {
+		base := path.Base(p)
+		prefix, name, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be NNN_description.sql", base)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version prefix %q", base, prefix)
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, base, version)
+		}
+		seen[version] = base
+
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", base, err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// applyMigration runs a single migration in a transaction and records it.
+// Several migrations rebuild tables with copy-drop-rename, which needs
+// foreign key enforcement off. SQLite ignores PRAGMA foreign_keys inside a
+// transaction, so we pin a connection and toggle it around the transaction,
+// then verify integrity with foreign_key_check before committing.
+func applyMigration(db *sql.DB, m migration) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migration %03d: get connection: %w", m.version, err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return fmt.Errorf("migration %03d: disable foreign keys: %w", m.version, err)
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %03d: begin: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("migration %03d_%s: %w", m.version, m.name, err)
+	}
+
+	fkRows, err := tx.Query(`PRAGMA foreign_key_check`)
+	if err != nil {
+		return fmt.Errorf("migration %03d: foreign key check: %w", m.version, err)
+	}
+	violations := fkRows.Next()
+	fkRows.Close()
+	if violations {
+		return fmt.Errorf("migration %03d_%s: foreign key violations", m.version, m.name)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now()); err != nil {
+		return fmt.Errorf("migration %03d: record version: %w", m.version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migration %03d: commit: %w", m.version, err)
+	}
+	return nil
+}
+
+// baselineLegacy records migrations that were already applied by older
+// binaries, which re-ran every migration on each boot without tracking.
+// It only acts when schema_migrations is empty but the schema exists.
+func baselineLegacy(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 || !tableExists(db, "repositories") {
+		return nil
+	}
+
+	// Each entry is applied if its marker is present in the live schema.
+	legacy := []struct {
+		version int
+		name    string
+		present bool
+	}{
+		{1, "initial", true},
+		{2, "add_gemini", tableSQLContains(db, "sessions", "'gemini'")},
+		{3, "add_local_repos", columnExists(db, "repositories", "repo_type")},
+		{4, "add_session_base", columnExists(db, "sessions", "base_commit")},
+		{5, "add_review_comments", tableExists(db, "review_comments")},
+	}
+	for _, l := range legacy {
+		if !l.present {
+			continue
+		}
+		if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, l.version, l.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tableExists(db *sql.DB, table string) bool {
+	var name string
+	err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+	return err == nil
+}
+
+// tableSQLContains reports whether the CREATE statement of table contains s.
+func tableSQLContains(db *sql.DB, table, s string) bool {
+	var createSQL string
+	err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&createSQL)
+	return err == nil && strings.Contains(createSQL, s)
+}
+
+func columnExists(db *sql.DB, table, column string) bool {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	return err == nil && count > 0
+}
@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// repoMigrations is the migrations directory the binary embeds.
+var repoMigrations = os.DirFS("../..")
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// applyLegacy runs migration files directly, without recording them, the way
+// binaries before schema_migrations did.
+func applyLegacy(t *testing.T, db *sql.DB, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		data, err := fs.ReadFile(repoMigrations, "migrations/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(data)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	migrations, err := loadMigrations(repoMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := migrations[len(migrations)-1].version
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, db *sql.DB)
+		wantErr string
+	}{
+		{"fresh", func(t *testing.T, db *sql.DB) {}, ""},
+		{"legacy baseline", func(t *testing.T, db *sql.DB) {
+			applyLegacy(t, db, "001_initial.sql", "002_add_gemini.sql", "003_add_local_repos.sql")
+		}, ""},
+		{"already migrated", func(t *testing.T, db *sql.DB) {
+			if err := Migrate(db, repoMigrations); err != nil {
+				t.Fatal(err)
+			}
+		}, ""},
+		{"newer database", func(t *testing.T, db *sql.DB) {
+			if err := Migrate(db, repoMigrations); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (?, 'future')`, latest+1); err != nil {
+				t.Fatal(err)
+			}
+		}, "newer than this binary supports"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			tt.setup(t, db)
+
+			err := Migrate(db, repoMigrations)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var count, maxVersion int
+			if err := db.QueryRow(`SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&count, &maxVersion); err != nil {
+				t.Fatal(err)
+			}
+			if count != len(migrations) || maxVersion != latest {
+				t.Errorf("recorded %d migrations up to %d, want %d up to %d", count, maxVersion, len(migrations), latest)
+			}
+			if !columnExists(db, "sessions", "stopped_at") {
+				t.Error("sessions.stopped_at missing after migrating")
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/peterje/superposition/internal/db"
+)
+
+// Credentials authenticate git operations. A token is handed to git through
+// GIT_ASKPASS for HTTPS remotes and an SSH key through GIT_SSH_COMMAND, so
+// neither ends up in the repository's config. The zero value means none.
+type Credentials struct {
+	Username   string // "x-access-token" for GitHub, "oauth2" for GitLab, ...
+	Token      string
+	SSHKeyPath string // private key for SSH remotes, e.g. a repo's deploy key
+
+	// TokenURL, if set, replaces Username and Token: the askpass helper
+	// fetches both from it on every prompt, authenticating with TokenKey.
+	// Agent sessions use it so they never hold the token itself.
+	TokenURL string
+	TokenKey string
+}
+
+// AskpassName is the name git runs the askpass helper under. It is a link to
+// the superposition binary, which answers git's prompt when run by that name.
+const AskpassName = "superposition-askpass"
+
+// Environment variables read by the askpass helper.
+const (
+	askpassUsernameEnv = "SUPERPOSITION_ASKPASS_USERNAME"
+	askpassTokenEnv    = "SUPERPOSITION_ASKPASS_TOKEN"
+	askpassURLEnv      = "SUPERPOSITION_ASKPASS_URL"
+	askpassKeyEnv      = "SUPERPOSITION_ASKPASS_KEY"
+)
+
+// askpassTimeout bounds the askpass helper's request for a token.
+const askpassTimeout = 30 * time.Second
+
+var (
+	askpassOnce sync.Once
+	askpassPath string
+)
+
+// askpassHelper returns the path of the askpass link in the data dir,
+// pointing it at the running binary first. It returns "" if that fails.
+func askpassHelper() string {
+	askpassOnce.Do(func() {
+		exe, err := os.Executable()
+		if err != nil {
+			return
+		}
+		dataDir, err := db.DataDir()
+		if err != nil {
+			return
+		}
+		path := filepath.Join(dataDir, "bin", AskpassName)
+		if target, err := os.Readlink(path); err == nil && target == exe {
+			askpassPath = path
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return
+		}
+		// Swap the link in with a rename so git never finds it missing.
+		tmp := path + ".tmp"
+		os.Remove(tmp)
+		if err := os.Symlink(exe, tmp); err != nil {
+			return
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return
+		}
+		askpassPath = path
+	})
+	return askpassPath
+}
+
+// Env returns the environment that makes git use the credentials. It is
+// applied to git commands run by the server and, with a TokenURL in place of
+// the token, to agent sessions, so pushing from inside a worktree
+// authenticates the same way.
+func (c Credentials) Env() []string {
+	var env []string
+	if c.Token != "" || c.TokenURL != "" {
+		if helper := askpassHelper(); helper != "" {
+			env = append(env, "GIT_ASKPASS="+helper)
+			if c.TokenURL != "" {
+				env = append(env, askpassURLEnv+"="+c.TokenURL, askpassKeyEnv+"="+c.TokenKey)
+			} else {
+				env = append(env, askpassUsernameEnv+"="+c.Username, askpassTokenEnv+"="+c.Token)
+			}
+		}
+	}
+	if c.SSHKeyPath != "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+strconv.Quote(c.SSHKeyPath)+
+			" -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new")
+	}
+	return env
+}
+
+// command returns a git command that authenticates with c and never prompts.
+func (c Credentials) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Env = append(cmd.Env, c.Env()...)
+	return cmd
+}
+
+// Askpass answers a GIT_ASKPASS prompt from the credentials in the
+// environment, or fetched from the TokenURL there: the username for
+// "Username for ..." and the token for anything else.
+func Askpass(prompt string) (string, error) {
+	username, token := os.Getenv(askpassUsernameEnv), os.Getenv(askpassTokenEnv)
+	if tokenURL := os.Getenv(askpassURLEnv); tokenURL != "" {
+		creds, err := fetchCredentials(tokenURL, os.Getenv(askpassKeyEnv))
+		if err != nil {
+			return "", err
+		}
+		username, token = creds.Username, creds.Token
+	}
+	if strings.HasPrefix(prompt, "Username") {
+		return username, nil
+	}
+	return token, nil
+}
+
+// fetchCredentials gets the current username and token from the server.
+func fetchCredentials(tokenURL, key string) (*Credentials, error) {
+	req, err := http.NewRequest("GET", tokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := (&http.Client{Timeout: askpassTimeout}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch credentials: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch credentials: %s", resp.Status)
+	}
+	var creds struct {
+		Username string `json:"username"`
+		Token    string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&creds); err != nil {
+		return nil, fmt.Errorf("fetch credentials: %w", err)
+	}
+	return &Credentials{Username: creds.Username, Token: creds.Token}, nil
+}
+
+// stripCredentials returns rawURL without a password in its userinfo.
+// Non-HTTP(S) URLs are returned unchanged.
+func stripCredentials(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.User == nil {
+		return rawURL
+	}
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// ScrubRemoteURL removes credentials embedded in the bare repo's
+// remote.origin.url, as older versions wrote them there. It reports whether
+// the URL was changed.
+func ScrubRemoteURL(barePath string) (bool, error) {
+	out, err := exec.Command("git", "-C", barePath, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return false, fmt.Errorf("git remote get-url: %w", err)
+	}
+	current := strings.TrimSpace(string(out))
+	clean := stripCredentials(current)
+	if clean == current {
+		return false, nil
+	}
+	if out, err := exec.Command("git", "-C", barePath, "remote", "set-url", "origin", clean).CombinedOutput(); err != nil {
+		return false, fmt.Errorf("git remote set-url: %s: %w", out, err)
+	}
+	return true, nil
+}
+
+// DeployKeyPath returns where the SSH deploy key of a repository is kept.
+// The public key is alongside it with a .pub suffix.
+func DeployKeyPath(repoID int64) (string, error) {
+	dataDir, err := db.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "keys", "repo-"+strconv.FormatInt(repoID, 10)), nil
+}
+
+// GenerateDeployKey creates a new ed25519 deploy key for a repository,
+// replacing any existing one, and returns its public key.
+func GenerateDeployKey(repoID int64) (string, error) {
+	path, err := DeployKeyPath(repoID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("create keys dir: %w", err)
+	}
+	if err := RemoveDeployKey(repoID); err != nil {
+		return "", err
+	}
+
+	comment := "superposition-repo-" + strconv.FormatInt(repoID, 10)
+	cmd := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", comment, "-f", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("ssh-keygen: %s: %w", out, err)
+	}
+	pub, _, err := DeployKey(repoID)
+	return pub, err
+}
+
+// DeployKey returns the repository's public deploy key and the path of its
+// private key, or empty strings if it has none.
+func DeployKey(repoID int64) (string, string, error) {
+	path, err := DeployKeyPath(repoID)
+	if err != nil {
+		return "", "", err
+	}
+	pub, err := os.ReadFile(path + ".pub")
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimSpace(string(pub)), path, nil
+}
+
+// RemoveDeployKey deletes the repository's deploy key, if any.
+func RemoveDeployKey(repoID int64) error {
+	path, err := DeployKeyPath(repoID)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{path, path + ".pub"} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove deploy key: %w", err)
+		}
+	}
+	return nil
+}
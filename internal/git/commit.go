@@ -0,0 +1,132 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrNothingToCommit is returned by CommitStaged when nothing is staged.
+var ErrNothingToCommit = errors.New("nothing to commit")
+
+// StatusEntry is one line of `git status --porcelain`.
+type StatusEntry struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"` // source of a rename or copy
+	Index    string `json:"index"`               // staged state: M, A, D, R, C, ? or space
+	Worktree string `json:"worktree"`            // unstaged state, same codes
+}
+
+// Identity is the author and committer of commits made through the API.
+// Empty fields fall back to git's own configuration.
+type Identity struct {
+	Name  string
+	Email string
+}
+
+// Status reports the worktree's staged, unstaged and untracked files.
+func Status(worktreePath string) ([]StatusEntry, error) {
+	out, err := exec.Command("git", "-C", worktreePath, "status", "--porcelain=v1", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status: %w", err)
+	}
+
+	entries := []StatusEntry{}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if len(f) < 4 {
+			continue
+		}
+		e := StatusEntry{Index: f[0:1], Worktree: f[1:2], Path: f[3:]}
+		// Renames and copies are followed by their source path.
+		if (e.Index == "R" || e.Index == "C") && i+1 < len(fields) {
+			i++
+			e.OrigPath = fields[i]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Stage stages paths, including deletions and new files. With no paths,
+// everything in the worktree is staged.
+func Stage(worktreePath string, paths []string) error {
+	args := []string{"-C", worktreePath, "add", "--all"}
+	if len(paths) == 0 {
+		args = append(args, "--", ".")
+	}
+	args = append(args, pathspecArgs(paths)...)
+	if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("git add: %s: %w", out, err)
+	}
+	return nil
+}
+
+// CommitStaged commits what is staged and returns the new commit's SHA.
+func CommitStaged(worktreePath, message string, author Identity) (string, error) {
+	if err := exec.Command("git", "-C", worktreePath, "diff", "--cached", "--quiet").Run(); err == nil {
+		return "", ErrNothingToCommit
+	}
+
+	cmd := exec.Command("git", "-C", worktreePath, "commit", "--quiet", "--no-verify", "-F", "-")
+	cmd.Stdin = strings.NewReader(message)
+	cmd.Env = append(os.Environ(), author.env()...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git commit: %s: %w", out, err)
+	}
+	return ResolveCommit(worktreePath, "HEAD")
+}
+
+// StagedFiles lists the paths staged for the next commit with their status
+// letter (A, M, D, R, ...).
+func StagedFiles(worktreePath string) ([]StatusEntry, error) {
+	entries, err := Status(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+	var staged []StatusEntry
+	for _, e := range entries {
+		if e.Index != " " && e.Index != "?" {
+			staged = append(staged, e)
+		}
+	}
+	return staged, nil
+}
+
+// CommitMessage generates a short message describing the staged files, for
+// when none is given.
+func CommitMessage(staged []StatusEntry) string {
+	verb := func(code string) string {
+		switch code {
+		case "A":
+			return "Add"
+		case "D":
+			return "Delete"
+		case "R":
+			return "Rename"
+		}
+		return "Update"
+	}
+
+	if len(staged) == 1 {
+		e := staged[0]
+		if e.Index == "R" {
+			return fmt.Sprintf("Rename %s to %s", e.OrigPath, e.Path)
+		}
+		return verb(e.Index) + " " + e.Path
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Update %d files\n\n", len(staged))
+	for _, e := range staged {
+		if e.Index == "R" {
+			fmt.Fprintf(&b, "- Rename %s to %s\n", e.OrigPath, e.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "- %s %s\n", verb(e.Index), e.Path)
+	}
+	return b.String()
+}
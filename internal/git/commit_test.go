@@ -0,0 +1,34 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStageLiteralPaths(t *testing.T) {
+	wt := newRepo(t, map[string]string{"a.go": "a\n", "b.go": "b\n"})
+	writeFiles(t, wt, map[string]string{"a.go": "a changed\n", "*.go": "new\n", "sub/c.go": "c\n"})
+
+	// As a pathspec, *.go would stage a.go and sub/c.go too.
+	if err := Stage(wt, []string{"*.go"}); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := StagedFiles(wt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range staged {
+		paths = append(paths, e.Path)
+	}
+	if want := []string{"*.go"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("staged %q, want %q", paths, want)
+	}
+
+	if err := Stage(wt, nil); err != nil {
+		t.Fatal(err)
+	}
+	if staged, _ := StagedFiles(wt); len(staged) != 3 {
+		t.Errorf("staged %+v after staging everything, want 3 files", staged)
+	}
+}
@@ -29,6 +29,13 @@ type DiffFile struct {
 	Hunks     []DiffHunk `json:"hunks"`
 	Additions int        `json:"additions"`
 	Deletions int        `json:"deletions"`
+	Location  string     `json:"location,omitempty"` // see the Location* constants
+
+	// Generated is set for files marked linguist-generated in .gitattributes.
+	// Truncated means Hunks were dropped by DiffLimits; fetch the file on its
+	// own to see them.
+	Generated bool `json:"generated,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // DiffHunk represents a single hunk in a file diff.
@@ -47,6 +54,10 @@ type DiffLine struct {
 	Content string `json:"content"`
 	OldNum  int    `json:"old_num,omitempty"`
 	NewNum  int    `json:"new_num,omitempty"`
+
+	// Changes marks the words that differ from the paired line on the other
+	// side, for modified lines only.
+	Changes []ChangeSpan `json:"changes,omitempty"`
 }
 
 // ResolveCommit resolves a git ref to a full commit SHA.
@@ -59,6 +70,19 @@ func ResolveCommit(repoOrWorktreePath, ref string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
+// ShowFile returns the contents of path as of commit.
+func ShowFile(repoOrWorktreePath, commit, path string) ([]byte, error) {
+	cmd := exec.Command("git", "-C", repoOrWorktreePath, "show", commit+":"+path)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git show: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("git show: %w", err)
+	}
+	return out, nil
+}
+
 // MergeBase finds the best common ancestor between two commits.
 func MergeBase(repoOrWorktreePath, ref1, ref2 string) (string, error) {
 	cmd := exec.Command("git", "-C", repoOrWorktreePath, "merge-base", ref1, ref2)
@@ -69,9 +93,21 @@ func MergeBase(repoOrWorktreePath, ref1, ref2 string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
-// Diff computes the diff between a base commit and the current working tree state.
+// Diff computes the diff between a base commit and the current working tree
+// state, including untracked files.
 func Diff(worktreePath, baseCommit string) (*DiffResult, error) {
-	cmd := exec.Command("git", "-C", worktreePath, "diff", baseCommit)
+	return DiffWithMode(worktreePath, baseCommit, DiffAll)
+}
+
+// DiffCommits returns the committed changes between two revisions, ignoring
+// any uncommitted work in the worktree.
+func DiffCommits(worktreePath, from, to string) (*DiffResult, error) {
+	return runDiff(worktreePath, from, to)
+}
+
+func runDiff(worktreePath string, revs ...string) (*DiffResult, error) {
+	args := append([]string{"-C", worktreePath, "diff"}, revs...)
+	cmd := exec.Command("git", args...)
 	out, err := cmd.Output()
 	if err != nil {
 		// git diff returns exit code 1 when there are differences in some modes,
@@ -86,6 +122,102 @@ func Diff(worktreePath, baseCommit string) (*DiffResult, error) {
 	return parseDiff(string(out))
 }
 
+// runRaw lists the files a diff touches with their status, without hunks or
+// line counts.
+func runRaw(worktreePath string, revs ...string) (*DiffResult, error) {
+	return runSummary(worktreePath, false, revs)
+}
+
+// runNumstat is runRaw with each file's added and deleted line counts.
+func runNumstat(worktreePath string, revs ...string) (*DiffResult, error) {
+	return runSummary(worktreePath, true, revs)
+}
+
+func runSummary(worktreePath string, numstat bool, revs []string) (*DiffResult, error) {
+	args := []string{"-C", worktreePath, "diff", "--raw", "-z"}
+	if numstat {
+		args = append(args, "--numstat")
+	}
+	out, err := exec.Command("git", append(args, revs...)...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git diff --raw: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("git diff --raw: %w", err)
+	}
+	return parseSummary(string(out))
+}
+
+// parseSummary parses `git diff --raw -z` output, optionally followed by
+// --numstat output for the same files in the same order.
+func parseSummary(raw string) (*DiffResult, error) {
+	result := &DiffResult{Files: []DiffFile{}}
+	// Every field is NUL-terminated; drop the last terminator so a truncated
+	// entry isn't completed by the empty string after it.
+	fields := strings.Split(strings.TrimSuffix(raw, "\x00"), "\x00")
+	i := 0
+
+	// :<old mode> <new mode> <old sha> <new sha> <status>, then the path, or
+	// the old and new paths for renames and copies.
+	for ; i < len(fields) && strings.HasPrefix(fields[i], ":"); i++ {
+		meta := strings.Fields(fields[i])
+		if len(meta) != 5 || i+1 >= len(fields) {
+			return nil, fmt.Errorf("malformed diff --raw line %q", fields[i])
+		}
+		f := DiffFile{Status: "modified", Hunks: []DiffHunk{}}
+		switch meta[4][0] {
+		case 'A':
+			f.Status = "added"
+		case 'D':
+			f.Status = "deleted"
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("malformed diff --raw line %q", fields[i])
+			}
+			i++
+			if meta[4][0] == 'R' {
+				f.Status, f.OldPath = "renamed", fields[i]
+			} else {
+				f.Status = "added" // a copy leaves its source in place
+			}
+		}
+		i++
+		f.Path = fields[i]
+		switch f.Status {
+		case "deleted":
+			f.OldPath, f.Path = f.Path, ""
+		case "modified":
+			f.OldPath = f.Path
+		}
+		result.Files = append(result.Files, f)
+	}
+
+	// <added> TAB <deleted> TAB <path>, or an empty path followed by the old
+	// and new paths. Binary files count "-".
+	for n := 0; n < len(result.Files) && i < len(fields); n++ {
+		counts := strings.SplitN(fields[i], "\t", 3)
+		if len(counts) != 3 {
+			break
+		}
+		if counts[2] == "" {
+			i += 2
+		}
+		i++
+		f := &result.Files[n]
+		if counts[0] == "-" {
+			f.Binary = true
+			continue
+		}
+		f.Additions, _ = strconv.Atoi(counts[0])
+		f.Deletions, _ = strconv.Atoi(counts[1])
+		result.Stats.Additions += f.Additions
+		result.Stats.Deletions += f.Deletions
+	}
+
+	result.Stats.FilesChanged = len(result.Files)
+	return result, nil
+}
+
 // parseDiff parses unified diff output into structured types.
 func parseDiff(raw string) (*DiffResult, error) {
 	result := &DiffResult{Files: []DiffFile{}}
@@ -122,7 +254,8 @@ func parseDiff(raw string) (*DiffResult, error) {
 
 		// Parse file metadata
 		if strings.HasPrefix(line, "--- ") {
-			path := strings.TrimPrefix(line, "--- ")
+			// git appends a tab to paths containing spaces
+			path := strings.TrimSuffix(strings.TrimPrefix(line, "--- "), "\t")
 			if path == "/dev/null" {
 				currentFile.Status = "added"
 			} else {
@@ -131,7 +264,7 @@ func parseDiff(raw string) (*DiffResult, error) {
 			continue
 		}
 		if strings.HasPrefix(line, "+++ ") {
-			path := strings.TrimPrefix(line, "+++ ")
+			path := strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t")
 			if path == "/dev/null" {
 				currentFile.Status = "deleted"
 			} else {
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSummary(t *testing.T) {
+	raw := func(fields ...string) string { return strings.Join(fields, "\x00") + "\x00" }
+	tests := []struct {
+		name      string
+		raw       string
+		want      []DiffFile
+		wantStats DiffStats
+	}{
+		{
+			name: "empty",
+			raw:  "",
+			want: []DiffFile{},
+		},
+		{
+			name: "raw only",
+			raw: raw(
+				":100644 100644 badc806 29a070e M", "my notes.txt",
+				":100644 000000 286c5f5 0000000 D", "d.txt",
+			),
+			want: []DiffFile{
+				{Path: "my notes.txt", OldPath: "my notes.txt", Status: "modified"},
+				{OldPath: "d.txt", Status: "deleted"},
+			},
+			wantStats: DiffStats{FilesChanged: 2},
+		},
+		{
+			name: "with numstat",
+			raw: raw(
+				":000000 100644 0000000 8ba3a16 A", "added file.txt",
+				":100644 100644 badc806 29a070e M", "b.bin",
+				":100644 100644 d68dd40 9405325 R080", "old name.txt", "new name.txt",
+				":100644 100644 d68dd40 9405325 C100", "a.txt", "copy of a.txt",
+				"1\t0\tadded file.txt",
+				"-\t-\tb.bin",
+				"1\t2\t", "old name.txt", "new name.txt",
+				"0\t0\t", "a.txt", "copy of a.txt",
+			),
+			want: []DiffFile{
+				{Path: "added file.txt", Status: "added", Additions: 1},
+				{Path: "b.bin", OldPath: "b.bin", Status: "modified", Binary: true},
+				{Path: "new name.txt", OldPath: "old name.txt", Status: "renamed", Additions: 1, Deletions: 2},
+				{Path: "copy of a.txt", Status: "added"},
+			},
+			wantStats: DiffStats{FilesChanged: 4, Additions: 2, Deletions: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSummary(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				tt.want[i].Hunks = []DiffHunk{}
+			}
+			if !reflect.DeepEqual(got.Files, tt.want) {
+				t.Errorf("files:\ngot  %+v\nwant %+v", got.Files, tt.want)
+			}
+			if got.Stats != tt.wantStats {
+				t.Errorf("stats: got %+v, want %+v", got.Stats, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestParseSummaryMalformed(t *testing.T) {
+	for _, raw := range []string{
+		":100644 100644 badc806 M\x00a.txt\x00",
+		":100644 100644 d68dd40 9405325 R100\x00old.txt\x00",
+		":100644 100644 badc806 29a070e M",
+	} {
+		if _, err := parseSummary(raw); err == nil {
+			t.Errorf("parseSummary(%q) succeeded, want an error", raw)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DiffMode selects which changes of a session worktree a diff covers.
+type DiffMode string
+
+const (
+	DiffCommitted DiffMode = "committed" // base commit to HEAD
+	DiffStaged    DiffMode = "staged"    // HEAD to the index
+	DiffUnstaged  DiffMode = "unstaged"  // index to the working tree, plus untracked files
+	DiffAll       DiffMode = "all"       // base commit to the working tree, plus untracked files
+)
+
+// Where a file's change lives, reported in DiffFile.Location.
+const (
+	LocationCommitted = "committed"
+	LocationStaged    = "staged"
+	LocationUnstaged  = "unstaged"
+	LocationUntracked = "untracked"
+	LocationMixed     = "mixed" // DiffAll only: changed in more than one place
+)
+
+// ParseDiffMode validates a mode from a query string. Empty means DiffAll.
+func ParseDiffMode(s string) (DiffMode, error) {
+	switch DiffMode(s) {
+	case "":
+		return DiffAll, nil
+	case DiffCommitted, DiffStaged, DiffUnstaged, DiffAll:
+		return DiffMode(s), nil
+	}
+	return "", fmt.Errorf("invalid diff mode %q", s)
+}
+
+// DiffWithMode computes the diff of a worktree for the given mode and tags
+// each file with where its change lives.
+func DiffWithMode(worktreePath, baseCommit string, mode DiffMode) (*DiffResult, error) {
+	return diffWithMode(worktreePath, baseCommit, mode, runDiff, nil)
+}
+
+// DiffSummary lists the files DiffWithMode would return, with their stats but
+// no hunks. Counts come from git diff --numstat, so no patch is parsed.
+func DiffSummary(worktreePath, baseCommit string, mode DiffMode) (*DiffResult, error) {
+	result, err := diffWithMode(worktreePath, baseCommit, mode, runNumstat, nil)
+	if err != nil {
+		return nil, err
+	}
+	result.Summarize() // untracked files are read whole
+	return result, nil
+}
+
+// DiffPath is DiffWithMode limited to one file. If the file was renamed, its
+// old path is diffed with it so git still pairs the two.
+func DiffPath(worktreePath, baseCommit string, mode DiffMode, path string) (*DiffResult, error) {
+	summary, err := diffWithMode(worktreePath, baseCommit, mode, runRaw, nil)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if f := summary.File(path); f != nil && f.OldPath != "" && f.OldPath != path {
+		paths = append(paths, f.OldPath)
+	}
+	return diffWithMode(worktreePath, baseCommit, mode, runDiff, paths)
+}
+
+// diffWithMode runs run over the revisions of mode, limited to paths if any
+// are given, then tags locations and adds untracked files.
+func diffWithMode(worktreePath, baseCommit string, mode DiffMode, run func(string, ...string) (*DiffResult, error), paths []string) (*DiffResult, error) {
+	var args []string
+	switch mode {
+	case DiffCommitted:
+		args = []string{baseCommit, "HEAD"}
+	case DiffStaged:
+		args = []string{"--cached", "HEAD"}
+	case DiffUnstaged:
+	case DiffAll:
+		args = []string{baseCommit}
+	default:
+		return nil, fmt.Errorf("invalid diff mode %q", mode)
+	}
+
+	result, err := run(worktreePath, append(args, pathspecArgs(paths)...)...)
+	if err != nil {
+		return nil, err
+	}
+	switch mode {
+	case DiffCommitted:
+		setLocation(result, LocationCommitted)
+	case DiffStaged:
+		setLocation(result, LocationStaged)
+	case DiffUnstaged:
+		setLocation(result, LocationUnstaged)
+	case DiffAll:
+		if err := tagLocations(worktreePath, baseCommit, result, paths); err != nil {
+			return nil, err
+		}
+	}
+	if mode == DiffUnstaged || mode == DiffAll {
+		if err := appendUntracked(worktreePath, result, paths); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// pathspecArgs turns paths into the trailing arguments of a git command,
+// matched literally rather than as globs. No paths means the whole tree.
+func pathspecArgs(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	args := []string{"--"}
+	for _, path := range paths {
+		args = append(args, ":(literal)"+path)
+	}
+	return args
+}
+
+func setLocation(result *DiffResult, location string) {
+	for i := range result.Files {
+		result.Files[i].Location = location
+	}
+}
+
+// tagLocations works out, for a net base-to-worktree diff, whether each file
+// was changed in commits, in the index, in the working tree, or a mix.
+func tagLocations(worktreePath, baseCommit string, result *DiffResult, paths []string) error {
+	sets := []struct {
+		location string
+		args     []string
+	}{
+		{LocationCommitted, []string{baseCommit, "HEAD"}},
+		{LocationStaged, []string{"--cached", "HEAD"}},
+		{LocationUnstaged, nil},
+	}
+	found := make(map[string][]string)
+	for _, set := range sets {
+		names, err := changedPaths(worktreePath, append(set.args, pathspecArgs(paths)...)...)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			found[name] = append(found[name], set.location)
+		}
+	}
+
+	for i := range result.Files {
+		f := &result.Files[i]
+		locations := found[f.Path]
+		if f.Path == "" {
+			locations = found[f.OldPath]
+		}
+		switch len(locations) {
+		case 0:
+			// e.g. a rename reported under its other name; treat as committed
+			f.Location = LocationCommitted
+		case 1:
+			f.Location = locations[0]
+		default:
+			f.Location = LocationMixed
+		}
+	}
+	return nil
+}
+
+// changedPaths lists paths touched by `git diff <args>`, both old and new
+// names for renames.
+func changedPaths(worktreePath string, args ...string) ([]string, error) {
+	cmdArgs := append([]string{"-C", worktreePath, "diff", "--name-only", "--no-renames", "-z"}, args...)
+	out, err := exec.Command("git", cmdArgs...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --name-only: %w", err)
+	}
+	var names []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Untracked files are read directly rather than diffed one git process at a
+// time. Past maxUntrackedFiles, or for files over maxUntrackedSize, only the
+// file is listed, marked Truncated.
+const (
+	maxUntrackedFiles = 1000
+	maxUntrackedSize  = 1 << 20 // 1MB
+)
+
+// appendUntracked adds untracked, non-ignored files to result as additions,
+// limited to paths if any are given.
+func appendUntracked(worktreePath string, result *DiffResult, paths []string) error {
+	args := append([]string{"-C", worktreePath, "ls-files", "--others", "--exclude-standard", "-z"}, pathspecArgs(paths)...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return fmt.Errorf("git ls-files: %w", err)
+	}
+	read := 0
+	for _, path := range strings.Split(string(out), "\x00") {
+		// Nested repositories are listed as directories; git can't diff them.
+		if path == "" || strings.HasSuffix(path, "/") {
+			continue
+		}
+		f := DiffFile{Path: path, Status: "added", Location: LocationUntracked, Hunks: []DiffHunk{}}
+		if read < maxUntrackedFiles {
+			read++
+			if err := addedFileDiff(filepath.Join(worktreePath, path), &f); err != nil {
+				return err
+			}
+		} else {
+			f.Truncated = true
+		}
+		result.Files = append(result.Files, f)
+		result.Stats.Additions += f.Additions
+	}
+	result.Stats.FilesChanged = len(result.Files)
+	return nil
+}
+
+// addedFileDiff fills in f as the diff that adds the file at fullPath: one
+// hunk of the whole file, nothing for a binary file, or Truncated if the file
+// is too large to read. Symlinks diff as their target, like git.
+func addedFileDiff(fullPath string, f *DiffFile) error {
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", f.Path, err)
+	}
+	var content []byte
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(fullPath)
+		if err != nil {
+			return fmt.Errorf("readlink %s: %w", f.Path, err)
+		}
+		content = []byte(target)
+	case !info.Mode().IsRegular():
+		return nil
+	case info.Size() > maxUntrackedSize:
+		f.Truncated = true
+		return nil
+	default:
+		if content, err = os.ReadFile(fullPath); err != nil {
+			return fmt.Errorf("read %s: %w", f.Path, err)
+		}
+	}
+
+	// git treats a file as binary if a NUL appears in its first 8000 bytes.
+	if bytes.IndexByte(content[:min(len(content), 8000)], 0) >= 0 {
+		f.Binary = true
+		return nil
+	}
+	if len(content) == 0 {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	hunk := DiffHunk{
+		Header:   fmt.Sprintf("@@ -0,0 +1,%d @@", len(lines)),
+		NewStart: 1,
+		NewCount: len(lines),
+		Lines:    make([]DiffLine, len(lines)),
+	}
+	if len(lines) == 1 {
+		hunk.Header = "@@ -0,0 +1 @@"
+	}
+	for i, line := range lines {
+		hunk.Lines[i] = DiffLine{Type: "add", Content: line, NewNum: i + 1}
+	}
+	f.Hunks = []DiffHunk{hunk}
+	f.Additions = len(lines)
+	return nil
+}
@@ -0,0 +1,136 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newSessionRepo creates a repo whose changes since the returned base commit
+// live in every place a diff mode distinguishes: a committed rename, a staged
+// edit, an unstaged edit and an untracked file, all with spaces in their names.
+func newSessionRepo(t *testing.T) (string, string) {
+	t.Helper()
+	dir := newRepo(t, map[string]string{
+		"old name.txt":    "one\ntwo\nthree\nfour\n",
+		"staged file.txt": "a\n",
+		"my notes.txt":    "x\n",
+	})
+	base := runGit(t, dir, "rev-parse", "HEAD")
+
+	runGit(t, dir, "mv", "old name.txt", "new name.txt")
+	runGit(t, dir, "commit", "-q", "-m", "rename")
+	writeFiles(t, dir, map[string]string{"staged file.txt": "a\nb\n"})
+	runGit(t, dir, "add", "staged file.txt")
+	writeFiles(t, dir, map[string]string{
+		"my notes.txt": "y\n",
+		"new file.txt": "1\n2\n",
+	})
+	return dir, base
+}
+
+type wantFile struct {
+	status, oldPath, location string
+	additions, deletions      int
+}
+
+func TestDiffWithMode(t *testing.T) {
+	dir, base := newSessionRepo(t)
+
+	tests := []struct {
+		mode DiffMode
+		want map[string]wantFile
+	}{
+		{DiffCommitted, map[string]wantFile{
+			"new name.txt": {"renamed", "old name.txt", LocationCommitted, 0, 0},
+		}},
+		{DiffStaged, map[string]wantFile{
+			"staged file.txt": {"modified", "staged file.txt", LocationStaged, 1, 0},
+		}},
+		{DiffUnstaged, map[string]wantFile{
+			"my notes.txt": {"modified", "my notes.txt", LocationUnstaged, 1, 1},
+			"new file.txt": {"added", "", LocationUntracked, 2, 0},
+		}},
+		{DiffAll, map[string]wantFile{
+			"new name.txt":    {"renamed", "old name.txt", LocationCommitted, 0, 0},
+			"staged file.txt": {"modified", "staged file.txt", LocationStaged, 1, 0},
+			"my notes.txt":    {"modified", "my notes.txt", LocationUnstaged, 1, 1},
+			"new file.txt":    {"added", "", LocationUntracked, 2, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			full, err := DiffWithMode(dir, base, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			summary, err := DiffSummary(dir, base, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, result := range map[string]*DiffResult{"diff": full, "summary": summary} {
+				if len(result.Files) != len(tt.want) {
+					t.Errorf("%s: got %d files, want %d: %+v", name, len(result.Files), len(tt.want), result.Files)
+				}
+				for _, f := range result.Files {
+					want, ok := tt.want[f.Path]
+					if !ok {
+						t.Errorf("%s: unexpected file %q", name, f.Path)
+						continue
+					}
+					got := wantFile{f.Status, f.OldPath, f.Location, f.Additions, f.Deletions}
+					if got != want {
+						t.Errorf("%s: %s: got %+v, want %+v", name, f.Path, got, want)
+					}
+				}
+			}
+			if full.Stats != summary.Stats {
+				t.Errorf("summary stats %+v differ from diff stats %+v", summary.Stats, full.Stats)
+			}
+		})
+	}
+}
+
+func TestDiffPathRenamed(t *testing.T) {
+	dir, base := newSessionRepo(t)
+	writeFiles(t, dir, map[string]string{"new name.txt": "one\ntwo\nthree\nfour\nfive\n"})
+
+	result, err := DiffPath(dir, base, DiffAll, "new name.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Files) != 1 {
+		t.Fatalf("got %d files, want 1: %+v", len(result.Files), result.Files)
+	}
+	f := result.Files[0]
+	if f.Status != "renamed" || f.OldPath != "old name.txt" || f.Additions != 1 || f.Location != LocationMixed {
+		t.Errorf("got %+v, want a rename from old name.txt adding one line, changed in more than one place", f)
+	}
+}
+
+func TestDiffUntrackedSkipsIgnored(t *testing.T) {
+	dir := newRepo(t, map[string]string{".gitignore": "*.log\n"})
+	base := runGit(t, dir, "rev-parse", "HEAD")
+	writeFiles(t, dir, map[string]string{"debug.log": "noise\n", "dir with space/new.txt": "hi\n"})
+	if err := os.Symlink("new.txt", filepath.Join(dir, "dir with space", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := DiffWithMode(dir, base, DiffUnstaged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int)
+	for _, f := range result.Files {
+		got[f.Path] = f.Additions
+	}
+	want := map[string]int{"dir with space/new.txt": 1, "dir with space/link": 1}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for path, n := range want {
+		if got[path] != n {
+			t.Errorf("%s: got %d additions, want %d", path, got[path], n)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package git
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChangeSpan marks a changed region within a DiffLine's content as a
+// half-open range of character (rune) offsets.
+type ChangeSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+const (
+	// Lines with more tokens than this are left unhighlighted; the LCS is
+	// quadratic.
+	maxIntralineTokens = 500
+	// Pairs sharing less than this fraction of their text are treated as
+	// rewrites rather than edits, so highlighting them would only add noise.
+	minIntralineSimilarity = 0.5
+)
+
+// AddIntraline marks changed words in every file that still has hunks. Call it
+// after ApplyLimits so truncated files are skipped.
+func (d *DiffResult) AddIntraline() {
+	for i := range d.Files {
+		d.Files[i].AddIntraline()
+	}
+}
+
+// AddIntraline marks changed words in each of the file's hunks.
+func (f *DiffFile) AddIntraline() {
+	for i := range f.Hunks {
+		addIntralineChanges(&f.Hunks[i])
+	}
+}
+
+// addIntralineChanges pairs each run of deleted lines with the run of added
+// lines that directly follows it and marks the words that differ.
+func addIntralineChanges(hunk *DiffHunk) {
+	lines := hunk.Lines
+	for i := 0; i < len(lines); {
+		if lines[i].Type != "delete" {
+			i++
+			continue
+		}
+		delStart := i
+		for i < len(lines) && lines[i].Type == "delete" {
+			i++
+		}
+		addStart := i
+		for i < len(lines) && lines[i].Type == "add" {
+			i++
+		}
+		dels, adds := lines[delStart:addStart], lines[addStart:i]
+		for j := 0; j < len(dels) && j < len(adds); j++ {
+			dels[j].Changes, adds[j].Changes = intralineChanges(dels[j].Content, adds[j].Content)
+		}
+	}
+}
+
+type token struct {
+	text  string
+	start int // rune offset
+	end   int
+}
+
+// tokenize splits s into words, runs of whitespace and single punctuation
+// characters.
+func tokenize(s string) []token {
+	var tokens []token
+	pos := 0
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		class := runeClass(r)
+		n, runes := size, 1
+		if class != classPunct {
+			for n < len(s) {
+				next, nextSize := utf8.DecodeRuneInString(s[n:])
+				if runeClass(next) != class {
+					break
+				}
+				n += nextSize
+				runes++
+			}
+		}
+		tokens = append(tokens, token{text: s[:n], start: pos, end: pos + runes})
+		s = s[n:]
+		pos += runes
+	}
+	return tokens
+}
+
+const (
+	classWord = iota
+	classSpace
+	classPunct
+)
+
+func runeClass(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return classWord
+	case unicode.IsSpace(r):
+		return classSpace
+	}
+	return classPunct
+}
+
+// intralineChanges returns the spans of old and new that are not part of
+// their longest common token subsequence, or nil for both if the lines are
+// too long or too different to be worth highlighting.
+func intralineChanges(old, new string) ([]ChangeSpan, []ChangeSpan) {
+	a, b := tokenize(old), tokenize(new)
+	if len(a) == 0 || len(b) == 0 || len(a) > maxIntralineTokens || len(b) > maxIntralineTokens {
+		return nil, nil
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i].text == b[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var oldSpans, newSpans []ChangeSpan
+	common := 0
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i].text == b[j].text:
+			common += a[i].end - a[i].start
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			newSpans = appendSpan(newSpans, b[j])
+			j++
+		default:
+			oldSpans = appendSpan(oldSpans, a[i])
+			i++
+		}
+	}
+
+	total := utf8.RuneCountInString(old) + utf8.RuneCountInString(new)
+	if float64(2*common)/float64(total) < minIntralineSimilarity {
+		return nil, nil
+	}
+	return oldSpans, newSpans
+}
+
+// appendSpan adds t to spans, merging it with the previous span if adjacent.
+func appendSpan(spans []ChangeSpan, t token) []ChangeSpan {
+	if n := len(spans); n > 0 && spans[n-1].End == t.start {
+		spans[n-1].End = t.end
+		return spans
+	}
+	return append(spans, ChangeSpan{Start: t.start, End: t.end})
+}
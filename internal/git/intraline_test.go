@@ -0,0 +1,50 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntralineChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		wantOld  []ChangeSpan
+		wantNew  []ChangeSpan
+	}{
+		{"operator", "return a + b", "return a - b", []ChangeSpan{{9, 10}}, []ChangeSpan{{9, 10}}},
+		{"insertion", "f(a)", "f(a, b)", nil, []ChangeSpan{{3, 6}}},
+		{"deletion", "x := y // note", "x := y", []ChangeSpan{{6, 14}}, nil},
+		{"rune offsets", "héllo wörld", "héllo world", []ChangeSpan{{6, 11}}, []ChangeSpan{{6, 11}}},
+		{"identical", "same line", "same line", nil, nil},
+		{"rewrite", "foo", "bar", nil, nil},
+		{"empty", "", "x", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOld, gotNew := intralineChanges(tt.old, tt.new)
+			if !reflect.DeepEqual(gotOld, tt.wantOld) || !reflect.DeepEqual(gotNew, tt.wantNew) {
+				t.Errorf("got %v, %v; want %v, %v", gotOld, gotNew, tt.wantOld, tt.wantNew)
+			}
+		})
+	}
+}
+
+func TestAddIntralinePairsRuns(t *testing.T) {
+	hunk := DiffHunk{Lines: []DiffLine{
+		{Type: "context", Content: "func f() {"},
+		{Type: "delete", Content: "	a := 1"},
+		{Type: "delete", Content: "	b := 2"},
+		{Type: "add", Content: "	a := 3"},
+		{Type: "context", Content: "}"},
+		{Type: "add", Content: "	c := 4"},
+	}}
+	addIntralineChanges(&hunk)
+
+	want := [][]ChangeSpan{nil, {{6, 7}}, nil, {{6, 7}}, nil, nil}
+	for i, line := range hunk.Lines {
+		if !reflect.DeepEqual(line.Changes, want[i]) {
+			t.Errorf("line %d %q: got %v, want %v", i, line.Content, line.Changes, want[i])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DiffLimits caps how many diff lines are returned so huge changes (lockfiles,
+// vendored code) don't produce multi-megabyte responses. Zero means no cap.
+type DiffLimits struct {
+	MaxFileLines  int // lines per file before its hunks are dropped
+	MaxTotalLines int // lines across all files before further files are dropped
+}
+
+// ApplyLimits drops the hunks of files that are generated or exceed the
+// limits, marking them Truncated. Stats are left untouched so they still
+// describe the whole diff.
+func (d *DiffResult) ApplyLimits(limits DiffLimits) {
+	total := 0
+	for i := range d.Files {
+		f := &d.Files[i]
+		lines := f.lineCount()
+		switch {
+		case f.Generated,
+			limits.MaxFileLines > 0 && lines > limits.MaxFileLines,
+			limits.MaxTotalLines > 0 && total+lines > limits.MaxTotalLines:
+			f.truncate()
+		default:
+			total += lines
+		}
+	}
+}
+
+// Summarize drops all hunks, leaving the file list and stats.
+func (d *DiffResult) Summarize() {
+	for i := range d.Files {
+		d.Files[i].Hunks = []DiffHunk{}
+	}
+}
+
+// File returns the file with the given path, or nil.
+func (d *DiffResult) File(path string) *DiffFile {
+	for i := range d.Files {
+		if d.Files[i].Path == path || (d.Files[i].Path == "" && d.Files[i].OldPath == path) {
+			return &d.Files[i]
+		}
+	}
+	return nil
+}
+
+func (f *DiffFile) lineCount() int {
+	n := 0
+	for _, h := range f.Hunks {
+		n += len(h.Lines)
+	}
+	return n
+}
+
+func (f *DiffFile) truncate() {
+	if len(f.Hunks) > 0 {
+		f.Hunks = []DiffHunk{}
+		f.Truncated = true
+	}
+}
+
+// TruncateFile drops the file's hunks if it has more than maxLines lines.
+func (f *DiffFile) TruncateFile(maxLines int) {
+	if maxLines > 0 && f.lineCount() > maxLines {
+		f.truncate()
+	}
+}
+
+// MarkGenerated flags files that .gitattributes marks linguist-generated.
+func MarkGenerated(worktreePath string, d *DiffResult) error {
+	if len(d.Files) == 0 {
+		return nil
+	}
+	var paths strings.Builder
+	for _, f := range d.Files {
+		path := f.Path
+		if path == "" {
+			path = f.OldPath
+		}
+		paths.WriteString(path)
+		paths.WriteByte(0)
+	}
+
+	cmd := exec.Command("git", "-C", worktreePath, "check-attr", "-z", "--stdin", "linguist-generated")
+	cmd.Stdin = strings.NewReader(paths.String())
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git check-attr: %w", err)
+	}
+
+	// Output is <path> NUL <attribute> NUL <value> NUL, repeated.
+	generated := make(map[string]bool)
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		if v := fields[i+2]; v == "set" || v == "true" {
+			generated[fields[i]] = true
+		}
+	}
+	for i := range d.Files {
+		f := &d.Files[i]
+		f.Generated = generated[f.Path] || (f.Path == "" && generated[f.OldPath])
+	}
+	return nil
+}
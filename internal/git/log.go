@@ -0,0 +1,100 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a single commit on a session branch.
+type Commit struct {
+	SHA         string    `json:"sha"`
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email"`
+	Subject     string    `json:"subject"`
+	Message     string    `json:"message"`
+	Timestamp   time.Time `json:"timestamp"`
+	Stats       DiffStats `json:"stats"`
+}
+
+var shaPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,64}$`)
+
+// ValidSHA reports whether s looks like a full or abbreviated commit hash.
+func ValidSHA(s string) bool {
+	return shaPattern.MatchString(s)
+}
+
+// Log lists the commits reachable from head but not from base, newest first,
+// with per-commit line stats.
+func Log(repoOrWorktreePath, base, head string) ([]Commit, error) {
+	// Each record starts with \x1e; fields are separated by \x00 and the
+	// --numstat lines follow the message.
+	cmd := exec.Command("git", "-C", repoOrWorktreePath, "log",
+		"--format=%x1e%H%x00%an%x00%ae%x00%at%x00%B%x00", "--numstat", base+".."+head)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git log: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	return parseLog(string(out)), nil
+}
+
+func parseLog(raw string) []Commit {
+	commits := []Commit{}
+	for _, record := range strings.Split(raw, "\x1e") {
+		fields := strings.SplitN(record, "\x00", 6)
+		if len(fields) < 6 {
+			continue
+		}
+		unix, _ := strconv.ParseInt(fields[3], 10, 64)
+		message := strings.TrimSpace(fields[4])
+		subject, _, _ := strings.Cut(message, "\n")
+		c := Commit{
+			SHA:         fields[0],
+			Author:      fields[1],
+			AuthorEmail: fields[2],
+			Subject:     subject,
+			Message:     message,
+			Timestamp:   time.Unix(unix, 0).UTC(),
+		}
+		for _, line := range strings.Split(fields[5], "\n") {
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			// Binary files report "-" for both counts.
+			added, _ := strconv.Atoi(parts[0])
+			deleted, _ := strconv.Atoi(parts[1])
+			c.Stats.FilesChanged++
+			c.Stats.Additions += added
+			c.Stats.Deletions += deleted
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+// CommitDiff returns the changes introduced by a single commit, relative to
+// its first parent.
+func CommitDiff(repoOrWorktreePath, sha string) (*DiffResult, error) {
+	cmd := exec.Command("git", "-C", repoOrWorktreePath, "show",
+		"--format=", "--first-parent", "-m", sha)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("git show: %s", string(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("git show: %w", err)
+	}
+	return parseDiff(string(out))
+}
+
+// IsAncestor reports whether commit is reachable from ref.
+func IsAncestor(repoOrWorktreePath, commit, ref string) bool {
+	return exec.Command("git", "-C", repoOrWorktreePath, "merge-base", "--is-ancestor", commit, ref).Run() == nil
+}
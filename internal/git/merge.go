@@ -0,0 +1,170 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// MergeStrategy is how a session branch is brought into its source branch.
+type MergeStrategy string
+
+const (
+	StrategyMerge  MergeStrategy = "merge"  // merge commit
+	StrategySquash MergeStrategy = "squash" // single squashed commit
+	StrategyRebase MergeStrategy = "rebase" // replay commits, then fast-forward
+)
+
+// ConflictError reports the files that conflicted while merging or rebasing.
+// The operation has been aborted and nothing was changed.
+type ConflictError struct {
+	Files []string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicts in %d file(s): %s", len(e.Files), strings.Join(e.Files, ", "))
+}
+
+// MergeInto combines branch into target inside a scratch worktree of the bare
+// repo and returns the resulting commit. Neither branch ref is moved; use
+// PushToOrigin to publish the result. message is used for merge and squash
+// commits.
+func MergeInto(barePath, branch, target string, strategy MergeStrategy, message string, author Identity) (string, error) {
+	base := target
+	if err := exec.Command("git", "-C", barePath, "rev-parse", "--verify", "refs/remotes/origin/"+target).Run(); err == nil {
+		base = "refs/remotes/origin/" + target
+	}
+
+	dir, err := os.MkdirTemp("", "superposition-merge-")
+	if err != nil {
+		return "", fmt.Errorf("create merge dir: %w", err)
+	}
+	os.Remove(dir) // git worktree add wants to create it
+	defer func() {
+		exec.Command("git", "-C", barePath, "worktree", "remove", "--force", dir).Run()
+		os.RemoveAll(dir)
+	}()
+
+	start := base
+	if strategy == StrategyRebase {
+		start = "refs/heads/" + branch
+	}
+	if out, err := exec.Command("git", "-C", barePath, "worktree", "add", "--detach", dir, start).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git worktree add: %s: %w", out, err)
+	}
+
+	run := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), author.env()...)
+		return cmd.CombinedOutput()
+	}
+
+	var opErr error
+	var out []byte
+	switch strategy {
+	case StrategyMerge:
+		out, opErr = run("merge", "--no-ff", "--no-edit", "-m", message, "refs/heads/"+branch)
+	case StrategySquash:
+		if out, opErr = run("merge", "--squash", "refs/heads/"+branch); opErr == nil {
+			if exec.Command("git", "-C", dir, "diff", "--cached", "--quiet").Run() == nil {
+				return "", ErrNothingToCommit
+			}
+			out, opErr = run("commit", "--quiet", "--no-verify", "-m", message)
+		}
+	case StrategyRebase:
+		out, opErr = run("rebase", base)
+	default:
+		return "", fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+
+	if opErr != nil {
+		if files := conflictedFiles(dir); len(files) > 0 {
+			if strategy == StrategyRebase {
+				run("rebase", "--abort")
+			} else {
+				run("merge", "--abort")
+			}
+			return "", &ConflictError{Files: files}
+		}
+		return "", fmt.Errorf("git %s: %s: %w", strategy, out, opErr)
+	}
+	return ResolveCommit(dir, "HEAD")
+}
+
+// PushToOrigin moves target in the origin repository to commit, which must
+// be a fast-forward. If origin is a non-bare checkout with target checked
+// out, its working tree is fast-forwarded instead, since git refuses to push
+// to a checked-out branch.
+func PushToOrigin(barePath, commit, target string) error {
+	originOut, err := exec.Command("git", "-C", barePath, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return fmt.Errorf("git remote get-url: %w", err)
+	}
+	origin := strings.TrimSpace(string(originOut))
+
+	head, _ := exec.Command("git", "-C", origin, "symbolic-ref", "--quiet", "--short", "HEAD").Output()
+	bare, _ := exec.Command("git", "-C", origin, "rev-parse", "--is-bare-repository").Output()
+	if strings.TrimSpace(string(head)) != target || strings.TrimSpace(string(bare)) == "true" {
+		cmd := exec.Command("git", "-C", barePath, "push", "origin", commit+":refs/heads/"+target)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git push: %s: %w", out, err)
+		}
+		return nil
+	}
+
+	// Expose the commit under a temporary ref so the checkout can fetch it.
+	tmpRef := "refs/superposition/merge-" + commit[:12]
+	if out, err := exec.Command("git", "-C", barePath, "update-ref", tmpRef, commit).CombinedOutput(); err != nil {
+		return fmt.Errorf("git update-ref: %s: %w", out, err)
+	}
+	defer exec.Command("git", "-C", barePath, "update-ref", "-d", tmpRef).Run()
+
+	if out, err := exec.Command("git", "-C", origin, "fetch", "--quiet", barePath, tmpRef).CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch: %s: %w", out, err)
+	}
+	if out, err := exec.Command("git", "-C", origin, "merge", "--ff-only", "--quiet", commit).CombinedOutput(); err != nil {
+		return fmt.Errorf("fast-forward %s in %s: %s: %w", target, origin, out, err)
+	}
+	return nil
+}
+
+// RebaseWorktree rebases the branch checked out in worktreePath onto onto,
+// stashing and restoring any uncommitted changes. On conflict the rebase is
+// aborted, leaving the branch as it was, and a *ConflictError is returned.
+func RebaseWorktree(worktreePath, onto string, author Identity) error {
+	cmd := exec.Command("git", "-C", worktreePath, "rebase", "--autostash", onto)
+	cmd.Env = append(os.Environ(), author.env()...)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if files := conflictedFiles(worktreePath); len(files) > 0 {
+		exec.Command("git", "-C", worktreePath, "rebase", "--abort").Run()
+		return &ConflictError{Files: files}
+	}
+	return fmt.Errorf("git rebase: %s: %w", out, err)
+}
+
+func conflictedFiles(dir string) []string {
+	out, _ := exec.Command("git", "-C", dir, "diff", "--name-only", "--diff-filter=U", "-z").Output()
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// env returns git environment overrides for the identity.
+func (id Identity) env() []string {
+	var env []string
+	if id.Name != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+id.Name, "GIT_COMMITTER_NAME="+id.Name)
+	}
+	if id.Email != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+id.Email, "GIT_COMMITTER_EMAIL="+id.Email)
+	}
+	return env
+}
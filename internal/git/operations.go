@@ -26,15 +26,16 @@ func WorktreesDir() (string, error) {
 	return filepath.Join(dataDir, "worktrees"), nil
 }
 
-// CloneBare clones a repo as a bare repository.
-// For private repos, the PAT is embedded in the URL.
-func CloneBare(cloneURL, pat, owner, name string) (string, error) {
+// CloneBare clones a repo as a bare repository at <repos dir>/<dir>/<name>.git.
+// creds authenticate private repos. progress, if non-nil, receives clone
+// progress.
+func CloneBare(cloneURL string, creds Credentials, dir, name string, progress ProgressFunc) (string, error) {
 	reposDir, err := ReposDir()
 	if err != nil {
 		return "", err
 	}
 
-	destDir := filepath.Join(reposDir, owner)
+	destDir := filepath.Join(reposDir, filepath.FromSlash(dir))
 	if err := os.MkdirAll(destDir, 0755); err != nil {
 		return "", fmt.Errorf("create repos dir: %w", err)
 	}
@@ -43,17 +44,12 @@ func CloneBare(cloneURL, pat, owner, name string) (string, error) {
 
 	// If already exists, just fetch
 	if _, err := os.Stat(localPath); err == nil {
-		return localPath, Fetch(localPath, pat)
+		return localPath, FetchWithProgress(localPath, creds, nil, progress)
 	}
 
-	authURL := cloneURL
-	if pat != "" {
-		authURL = strings.Replace(cloneURL, "https://", "https://x-access-token:"+pat+"@", 1)
-	}
-
-	cmd := exec.Command("git", "clone", "--bare", authURL, localPath)
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("git clone: %s: %w", string(out), err)
+	cmd := creds.command("clone", "--bare", "--progress", cloneURL, localPath)
+	if out, err := runWithProgress(cmd, progress); err != nil {
+		return "", gitError("clone", out, creds.Token, err)
 	}
 
 	// git clone --bare doesn't set a fetch refspec. Configure it to fetch into
@@ -65,7 +61,7 @@ func CloneBare(cloneURL, pat, owner, name string) (string, error) {
 
 // CloneBareLocal clones a local git repo as a bare repository.
 // No PAT needed — uses direct filesystem path as origin.
-func CloneBareLocal(sourcePath, name string) (string, error) {
+func CloneBareLocal(sourcePath, name string, progress ProgressFunc) (string, error) {
 	// Validate that sourcePath is a git repo
 	cmd := exec.Command("git", "-C", sourcePath, "rev-parse", "--git-dir")
 	if out, err := cmd.CombinedOutput(); err != nil {
@@ -94,12 +90,12 @@ func CloneBareLocal(sourcePath, name string) (string, error) {
 				return "", fmt.Errorf("bare repo already exists with different origin: %s", existingOrigin)
 			}
 		}
-		return localPath, Fetch(localPath, "")
+		return localPath, FetchWithProgress(localPath, Credentials{}, nil, progress)
 	}
 
-	cloneCmd := exec.Command("git", "clone", "--bare", sourcePath, localPath)
-	if out, err := cloneCmd.CombinedOutput(); err != nil {
-		return "", fmt.Errorf("git clone: %s: %w", string(out), err)
+	cloneCmd := exec.Command("git", "clone", "--bare", "--progress", sourcePath, localPath)
+	if out, err := runWithProgress(cloneCmd, progress); err != nil {
+		return "", gitError("clone", out, "", err)
 	}
 
 	// Configure fetch refspec for bare clone
@@ -108,44 +104,75 @@ func CloneBareLocal(sourcePath, name string) (string, error) {
 	return localPath, nil
 }
 
-func Fetch(barePath, pat string) error {
+// Fetch updates the bare repo from origin. Local branches in keep, e.g.
+// those of sessions, are left where they are.
+func Fetch(barePath string, creds Credentials, keep map[string]bool) error {
+	return FetchWithProgress(barePath, creds, keep, nil)
+}
+
+// FetchWithProgress is Fetch, reporting progress to progress if non-nil.
+func FetchWithProgress(barePath string, creds Credentials, keep map[string]bool, progress ProgressFunc) error {
 	// Fetch into a remote-tracking namespace to avoid conflicts with branches
 	// checked out in worktrees. We then fast-forward local branches that
 	// aren't currently checked out.
 	exec.Command("git", "-C", barePath, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*").Run()
 
-	cmd := exec.Command("git", "-C", barePath, "fetch", "--all", "--prune")
-	if pat != "" {
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("GIT_ASKPASS=echo"),
-			fmt.Sprintf("GIT_TERMINAL_PROMPT=0"),
-		)
-		// Set the remote URL with auth for fetch
-		setURL := exec.Command("git", "-C", barePath, "remote", "set-url", "origin",
-			getAuthURL(barePath, pat))
-		setURL.Run() // best effort
+	// Credentials are supplied at runtime; drop any a previous version
+	// stored in the remote URL.
+	ScrubRemoteURL(barePath)
+
+	cmd := creds.command("-C", barePath, "fetch", "--all", "--prune", "--progress")
+	if out, err := runWithProgress(cmd, progress); err != nil {
+		return gitError("fetch", out, creds.Token, err)
 	}
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("git fetch: %s: %w", string(out), err)
+
+	// Record the remote's default branch as origin/HEAD; DefaultBranch reads
+	// it. Asking the remote is another round trip, so only do it once.
+	if exec.Command("git", "-C", barePath, "symbolic-ref", "--quiet", "refs/remotes/origin/HEAD").Run() != nil {
+		creds.command("-C", barePath, "remote", "set-head", "origin", "--auto").Run()
+	}
+
+	fastForwardBranches(barePath, keep)
+	return nil
+}
+
+// fastForwardBranches moves local branches up to their remote-tracking refs,
+// creating any that are missing. Branches checked out in a worktree or in
+// keep are skipped, and so is any that has commits its remote-tracking ref
+// lacks, so nothing that was never pushed is discarded.
+func fastForwardBranches(barePath string, keep map[string]bool) {
+	out, err := exec.Command("git", "-C", barePath, "for-each-ref", "--format=%(objectname) %(refname)",
+		"refs/heads/", "refs/remotes/origin/").Output()
+	if err != nil {
+		return
+	}
+	local := make(map[string]string)
+	remote := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		sha, ref, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+			local[branch] = sha
+		} else if branch, ok := strings.CutPrefix(ref, "refs/remotes/origin/"); ok && branch != "HEAD" {
+			remote[branch] = sha
+		}
 	}
 
-	// Update local branches from remote-tracking refs, skipping any that are
-	// checked out in a worktree.
 	checkedOut := worktreeBranches(barePath)
-	remotes, _ := exec.Command("git", "-C", barePath, "for-each-ref", "--format=%(refname:short)", "refs/remotes/origin/").Output()
-	for _, line := range strings.Split(strings.TrimSpace(string(remotes)), "\n") {
-		branch := strings.TrimPrefix(line, "origin/")
-		if branch == "" || branch == "HEAD" {
+	for branch, sha := range remote {
+		old, exists := local[branch]
+		if old == sha || checkedOut[branch] || keep[branch] {
 			continue
 		}
-		if checkedOut[branch] {
+		if exists && exec.Command("git", "-C", barePath, "merge-base", "--is-ancestor", old, sha).Run() != nil {
 			continue
 		}
-		// Fast-forward the local branch to match the remote-tracking ref
-		exec.Command("git", "-C", barePath, "update-ref", "refs/heads/"+branch, "refs/remotes/origin/"+branch).Run()
+		// Passing the old value makes the update fail if the branch moved
+		// since it was read, e.g. by a commit in a session.
+		exec.Command("git", "-C", barePath, "update-ref", "refs/heads/"+branch, sha, old).Run()
 	}
-
-	return nil
 }
 
 // worktreeBranches returns the set of branch names currently checked out in any worktree.
@@ -163,22 +190,6 @@ func worktreeBranches(barePath string) map[string]bool {
 	return branches
 }
 
-func getAuthURL(barePath, pat string) string {
-	cmd := exec.Command("git", "-C", barePath, "remote", "get-url", "origin")
-	out, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	url := strings.TrimSpace(string(out))
-	// Strip any existing credentials
-	url = strings.Replace(url, "x-access-token:"+pat+"@", "", 1)
-	// Re-add our token
-	if pat != "" {
-		url = strings.Replace(url, "https://", "https://x-access-token:"+pat+"@", 1)
-	}
-	return url
-}
-
 // AddWorktree creates a new worktree with a new branch based off a source branch.
 // newBranch is the name of the branch to create, sourceBranch is the branch to base it on.
 func AddWorktree(barePath, worktreePath, newBranch, sourceBranch string) error {
@@ -200,6 +211,40 @@ func AddWorktree(barePath, worktreePath, newBranch, sourceBranch string) error {
 	return nil
 }
 
+// RestoreWorktree re-creates a worktree for an existing branch, e.g. when a
+// stopped session is restarted after its worktree was cleaned up.
+func RestoreWorktree(barePath, worktreePath, branch string) error {
+	if err := os.MkdirAll(filepath.Dir(worktreePath), 0755); err != nil {
+		return fmt.Errorf("create worktree parent: %w", err)
+	}
+
+	// Forget worktrees whose directories are gone so the branch isn't
+	// considered checked out elsewhere.
+	exec.Command("git", "-C", barePath, "worktree", "prune").Run()
+
+	cmd := exec.Command("git", "-C", barePath, "worktree", "add", worktreePath, branch)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree add: %s: %w", string(out), err)
+	}
+	return nil
+}
+
+// Push pushes a session branch of the bare repo to origin, authenticating
+// with creds if given. Rebasing a session rewrites its branch, so the push is
+// forced, but only while origin's branch is where it was last fetched;
+// commits pushed there since are never overwritten.
+func Push(barePath, branch string, creds Credentials) error {
+	ref := "refs/heads/" + branch
+	// An empty expected value means the branch must not exist on origin yet.
+	expect, _ := exec.Command("git", "-C", barePath, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch).Output()
+	lease := "--force-with-lease=" + ref + ":" + strings.TrimSpace(string(expect))
+	cmd := creds.command("-C", barePath, "push", lease, "origin", ref+":"+ref)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return gitError("push", out, creds.Token, err)
+	}
+	return nil
+}
+
 func RemoveWorktree(barePath, worktreePath string) error {
 	cmd := exec.Command("git", "-C", barePath, "worktree", "remove", "--force", worktreePath)
 	if out, err := cmd.CombinedOutput(); err != nil {
@@ -0,0 +1,156 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGit runs git in dir and returns its trimmed output, failing the test on
+// error.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_NOSYSTEM=1", "HOME="+dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %s: %v", strings.Join(args, " "), out, err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// newRepo creates a repository with one commit of files on main.
+func newRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	writeFiles(t, dir, files)
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	return dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// commitFile commits content to name on the branch checked out in dir and
+// returns the new commit.
+func commitFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	writeFiles(t, dir, map[string]string{name: content})
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "change "+name)
+	return runGit(t, dir, "rev-parse", "HEAD")
+}
+
+func TestFetchFastForwardsOnly(t *testing.T) {
+	origin := newRepo(t, map[string]string{"f.txt": "a\n"})
+	for _, b := range []string{"behind", "diverged", "session"} {
+		runGit(t, origin, "branch", b)
+	}
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, origin, "clone", "-q", "--bare", origin, bare)
+	if err := Fetch(bare, Credentials{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Local work on two branches, upstream work on three plus a new one.
+	wt := filepath.Join(t.TempDir(), "wt")
+	runGit(t, bare, "worktree", "add", "-q", wt, "diverged")
+	unpushed := commitFile(t, wt, "local.txt", "mine\n")
+	runGit(t, bare, "worktree", "remove", wt)
+	runGit(t, bare, "branch", "-f", "session", "main")
+
+	upstream := make(map[string]string)
+	for _, b := range []string{"behind", "diverged", "session"} {
+		runGit(t, origin, "checkout", "-q", b)
+		upstream[b] = commitFile(t, origin, b+".txt", "theirs\n")
+	}
+	runGit(t, origin, "checkout", "-q", "-b", "new", "main")
+	upstream["new"] = commitFile(t, origin, "new.txt", "new\n")
+	session := runGit(t, bare, "rev-parse", "refs/heads/session")
+
+	if err := Fetch(bare, Credentials{}, map[string]bool{"session": true}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"behind":   upstream["behind"],
+		"new":      upstream["new"],
+		"diverged": unpushed,
+		"session":  session,
+	}
+	for branch, sha := range want {
+		if got := runGit(t, bare, "rev-parse", "refs/heads/"+branch); got != sha {
+			t.Errorf("%s = %s, want %s", branch, got, sha)
+		}
+	}
+}
+
+func TestPushAfterRebase(t *testing.T) {
+	seed := newRepo(t, map[string]string{"f.txt": "a\n"})
+	origin := filepath.Join(t.TempDir(), "origin.git")
+	runGit(t, seed, "clone", "-q", "--bare", seed, origin)
+	runGit(t, seed, "remote", "add", "up", origin)
+	bare := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, seed, "clone", "-q", "--bare", origin, bare)
+	if err := Fetch(bare, Credentials{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	wt := filepath.Join(t.TempDir(), "wt")
+	if err := AddWorktree(bare, wt, "feature", "main"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, wt, "feature.txt", "one\n")
+	if err := Push(bare, "feature", Credentials{}); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+
+	// main moves on upstream and the session rebases onto it, rewriting the
+	// already pushed branch.
+	commitFile(t, seed, "main.txt", "upstream\n")
+	runGit(t, seed, "push", "-q", "up", "main")
+	keep := map[string]bool{"feature": true}
+	if err := Fetch(bare, Credentials{}, keep); err != nil {
+		t.Fatal(err)
+	}
+	upstream := runGit(t, bare, "rev-parse", "refs/remotes/origin/main")
+	if err := RebaseWorktree(wt, upstream, Identity{Name: "Test", Email: "test@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Push(bare, "feature", Credentials{}); err != nil {
+		t.Fatalf("push after rebase: %v", err)
+	}
+	if got, want := runGit(t, origin, "rev-parse", "feature"), runGit(t, wt, "rev-parse", "HEAD"); got != want {
+		t.Errorf("origin feature = %s, want %s", got, want)
+	}
+
+	// Someone else pushes to the branch; without a fetch, pushing must not
+	// overwrite their commit.
+	runGit(t, seed, "fetch", "-q", "up", "feature:feature")
+	runGit(t, seed, "checkout", "-q", "feature")
+	theirs := commitFile(t, seed, "theirs.txt", "theirs\n")
+	runGit(t, seed, "push", "-q", "up", "feature")
+	commitFile(t, wt, "feature.txt", "two\n")
+	if err := Push(bare, "feature", Credentials{}); err == nil {
+		t.Error("push over a commit pushed by someone else succeeded")
+	}
+	if got := runGit(t, origin, "rev-parse", "feature"); got != theirs {
+		t.Errorf("origin feature = %s, want their commit %s", got, theirs)
+	}
+}
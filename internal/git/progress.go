@@ -0,0 +1,103 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is one update parsed from git's --progress output, such as
+// "Receiving objects:  45% (450/1000)".
+type Progress struct {
+	Phase   string `json:"phase"`   // e.g. "Receiving objects", "Resolving deltas"
+	Percent int    `json:"percent"` // 0-100 within the phase
+}
+
+// ProgressFunc receives progress updates as a clone or fetch runs.
+type ProgressFunc func(Progress)
+
+var progressLine = regexp.MustCompile(`^(?:remote: )?([A-Za-z][A-Za-z ]*?):\s+(\d{1,3})%`)
+
+// parseProgress extracts the phase and percentage from one line of git's
+// progress output.
+func parseProgress(line string) (Progress, bool) {
+	m := progressLine.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return Progress{}, false
+	}
+	pct, _ := strconv.Atoi(m[2])
+	if pct > 100 {
+		pct = 100
+	}
+	return Progress{Phase: m[1], Percent: pct}, true
+}
+
+// runWithProgress runs cmd, reporting progress parsed from its stderr, and
+// returns everything it printed, stdout first. Progress lines are terminated
+// by \r, so stderr is split on both \r and \n; only the final state of each
+// progress line is kept in the returned output.
+func runWithProgress(cmd *exec.Cmd, progress ProgressFunc) ([]byte, error) {
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	// exec copies stdout from its own goroutine, so it gets a buffer of its
+	// own rather than sharing the one the stderr loop below writes to.
+	var stdout, out bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(stderr)
+	scanner.Split(scanProgressLines)
+	last := Progress{Percent: -1}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if p, ok := parseProgress(line); ok {
+			if progress != nil && p != last {
+				progress(p)
+				last = p
+			}
+			if p.Percent < 100 {
+				continue
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	io.Copy(io.Discard, stderr) // drain if the scanner stopped early
+
+	err = cmd.Wait()
+	stdout.Write(out.Bytes())
+	return stdout.Bytes(), err
+}
+
+// scanProgressLines is bufio.ScanLines, but also breaking on \r.
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// gitError formats the failure of a git subcommand, redacting secret from its
+// output.
+func gitError(op string, out []byte, secret string, err error) error {
+	msg := strings.TrimSpace(string(out))
+	if secret != "" {
+		msg = strings.ReplaceAll(msg, secret, "***")
+	}
+	return fmt.Errorf("git %s: %s: %w", op, msg, err)
+}
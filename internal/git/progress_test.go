@@ -0,0 +1,52 @@
+package git
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want Progress
+		ok   bool
+	}{
+		{"Receiving objects:  45% (450/1000)", Progress{"Receiving objects", 45}, true},
+		{"remote: Counting objects: 100% (12/12), done.", Progress{"Counting objects", 100}, true},
+		{"Resolving deltas: 250%", Progress{"Resolving deltas", 100}, true},
+		{"Fetching origin", Progress{}, false},
+		{"fatal: repository not found", Progress{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseProgress(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseProgress(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestRunWithProgressBothStreams runs a command that writes to stdout and
+// stderr at the same time; run with -race to check the buffers aren't shared.
+func TestRunWithProgressBothStreams(t *testing.T) {
+	script := `for i in 1 2 3 4 5; do echo "Fetching origin $i"; done &
+printf 'Receiving objects:  50%% (1/2)\rReceiving objects: 100%% (2/2), done.\n' >&2
+echo "fatal: done" >&2
+wait`
+	var got []Progress
+	out, err := runWithProgress(exec.Command("sh", "-c", script), func(p Progress) {
+		got = append(got, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Progress{{"Receiving objects", 50}, {"Receiving objects", 100}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("progress = %+v, want %+v", got, want)
+	}
+	const wantOut = "Fetching origin 1\nFetching origin 2\nFetching origin 3\nFetching origin 4\nFetching origin 5\n" +
+		"Receiving objects: 100% (2/2), done.\nfatal: done\n"
+	if string(out) != wantOut {
+		t.Errorf("output = %q, want %q", out, wantOut)
+	}
+}
@@ -0,0 +1,179 @@
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ref is a branch, remote-tracking branch or tag with its latest commit.
+type Ref struct {
+	Name   string    `json:"name"` // short name, e.g. main, origin/main, v1.0
+	SHA    string    `json:"sha"`
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+	// Commits on the ref but not the default branch, and the reverse. Only
+	// set for branches, and with git older than 2.41 only for the
+	// maxAheadBehind most recently updated ones.
+	Ahead  *int `json:"ahead,omitempty"`
+	Behind *int `json:"behind,omitempty"`
+}
+
+// maxAheadBehind caps how many branches get ahead/behind counts when each
+// needs its own git rev-list.
+const maxAheadBehind = 50
+
+// Refs are the refs of a bare repository, grouped by kind.
+type Refs struct {
+	DefaultBranch  string `json:"default_branch"`
+	Branches       []Ref  `json:"branches"`
+	RemoteBranches []Ref  `json:"remote_branches"`
+	Tags           []Ref  `json:"tags"`
+}
+
+// ListRefs returns the branches, remote-tracking branches and tags of a bare
+// repo. Branches are compared with defaultBranch for their ahead/behind
+// counts.
+func ListRefs(barePath, defaultBranch string) (*Refs, error) {
+	base := ""
+	if defaultBranch != "" {
+		if sha, err := ResolveCommit(barePath, "refs/heads/"+defaultBranch); err == nil {
+			base = sha
+		}
+	}
+
+	// Annotated tags point at a tag object; the %(*...) fields peel it to
+	// the commit. git 2.41+ counts ahead/behind for every ref in one pass.
+	format := "%(refname)%00%(symref)%00%(objectname)%00%(authorname)%00%(committerdate:unix)%00%(*objectname)%00%(*authorname)%00%(*committerdate:unix)"
+	batched := base != "" && hasAheadBehindAtom(barePath, base)
+	if batched {
+		format += "%00%(ahead-behind:" + base + ")"
+	}
+	cmd := exec.Command("git", "-C", barePath, "for-each-ref", "--format="+format,
+		"refs/heads", "refs/remotes", "refs/tags")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git for-each-ref: %w", err)
+	}
+
+	refs := &Refs{DefaultBranch: defaultBranch, Branches: []Ref{}, RemoteBranches: []Ref{}, Tags: []Ref{}}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) < 8 || fields[1] != "" { // skip origin/HEAD
+			continue
+		}
+		ref := Ref{SHA: fields[2], Author: fields[3]}
+		unix := fields[4]
+		if fields[5] != "" {
+			ref.SHA, ref.Author, unix = fields[5], fields[6], fields[7]
+		}
+		if secs, err := strconv.ParseInt(unix, 10, 64); err == nil {
+			ref.Date = time.Unix(secs, 0).UTC()
+		}
+
+		name := fields[0]
+		switch {
+		case strings.HasPrefix(name, "refs/heads/"):
+			ref.Name = strings.TrimPrefix(name, "refs/heads/")
+			if batched && len(fields) == 9 {
+				ref.Ahead, ref.Behind = parseAheadBehind(fields[8], false)
+			}
+			refs.Branches = append(refs.Branches, ref)
+		case strings.HasPrefix(name, "refs/remotes/"):
+			ref.Name = strings.TrimPrefix(name, "refs/remotes/")
+			if batched && len(fields) == 9 {
+				ref.Ahead, ref.Behind = parseAheadBehind(fields[8], false)
+			}
+			refs.RemoteBranches = append(refs.RemoteBranches, ref)
+		case strings.HasPrefix(name, "refs/tags/"):
+			ref.Name = strings.TrimPrefix(name, "refs/tags/")
+			refs.Tags = append(refs.Tags, ref)
+		}
+	}
+
+	if base != "" && !batched {
+		countRecent(barePath, base, refs)
+	}
+	return refs, nil
+}
+
+// countRecent fills in ahead/behind counts for the most recently updated
+// branches, one git rev-list each.
+func countRecent(barePath, base string, refs *Refs) {
+	var branches []*Ref
+	for i := range refs.Branches {
+		branches = append(branches, &refs.Branches[i])
+	}
+	for i := range refs.RemoteBranches {
+		branches = append(branches, &refs.RemoteBranches[i])
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i].Date.After(branches[j].Date)
+	})
+	for _, ref := range branches[:min(len(branches), maxAheadBehind)] {
+		ref.Ahead, ref.Behind = aheadBehind(barePath, base, ref.SHA)
+	}
+}
+
+// aheadBehind counts the commits on sha but not base and on base but not sha.
+func aheadBehind(barePath, base, sha string) (*int, *int) {
+	out, err := exec.Command("git", "-C", barePath, "rev-list", "--left-right", "--count", base+"..."+sha).Output()
+	if err != nil {
+		return nil, nil
+	}
+	return parseAheadBehind(string(out), true)
+}
+
+// parseAheadBehind parses two counts separated by whitespace: ahead then
+// behind, or behind then ahead if reversed.
+func parseAheadBehind(s string, reversed bool) (*int, *int) {
+	counts := strings.Fields(s)
+	if len(counts) != 2 {
+		return nil, nil
+	}
+	ahead, err1 := strconv.Atoi(counts[0])
+	behind, err2 := strconv.Atoi(counts[1])
+	if err1 != nil || err2 != nil {
+		return nil, nil
+	}
+	if reversed {
+		ahead, behind = behind, ahead
+	}
+	return &ahead, &behind
+}
+
+var (
+	aheadBehindOnce      sync.Once
+	aheadBehindSupported bool
+)
+
+// hasAheadBehindAtom reports whether git's for-each-ref knows
+// %(ahead-behind:...), added in git 2.41. base must be a valid commit.
+func hasAheadBehindAtom(barePath, base string) bool {
+	aheadBehindOnce.Do(func() {
+		err := exec.Command("git", "-C", barePath, "for-each-ref", "--count=1", "--format=%(ahead-behind:"+base+")").Run()
+		aheadBehindSupported = err == nil
+	})
+	return aheadBehindSupported
+}
+
+// DefaultBranch returns the branch the remote's HEAD points to: origin/HEAD
+// if a fetch recorded it, otherwise the HEAD the bare clone copied from the
+// remote. It returns "" if neither names an existing branch.
+func DefaultBranch(barePath string) string {
+	for _, ref := range []string{"refs/remotes/origin/HEAD", "HEAD"} {
+		out, err := exec.Command("git", "-C", barePath, "symbolic-ref", "--short", ref).Output()
+		if err != nil {
+			continue
+		}
+		branch := strings.TrimPrefix(strings.TrimSpace(string(out)), "origin/")
+		if exec.Command("git", "-C", barePath, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch).Run() == nil {
+			return branch
+		}
+	}
+	return ""
+}
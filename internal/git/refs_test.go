@@ -0,0 +1,62 @@
+package git
+
+import "testing"
+
+func TestParseAheadBehind(t *testing.T) {
+	tests := []struct {
+		in                    string
+		reversed              bool
+		wantAhead, wantBehind int
+		wantNil               bool
+	}{
+		{in: "3 1", wantAhead: 3, wantBehind: 1},
+		{in: "3\t1\n", reversed: true, wantAhead: 1, wantBehind: 3},
+		{in: "0 0", wantAhead: 0, wantBehind: 0},
+		{in: "", wantNil: true},
+		{in: "3", wantNil: true},
+		{in: "3 1 2", wantNil: true},
+		{in: "x 1", wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			ahead, behind := parseAheadBehind(tt.in, tt.reversed)
+			if tt.wantNil {
+				if ahead != nil || behind != nil {
+					t.Errorf("got %v, %v; want nil", ahead, behind)
+				}
+				return
+			}
+			if ahead == nil || behind == nil || *ahead != tt.wantAhead || *behind != tt.wantBehind {
+				t.Fatalf("got %v, %v; want %d, %d", ahead, behind, tt.wantAhead, tt.wantBehind)
+			}
+		})
+	}
+}
+
+func TestListRefsAheadBehind(t *testing.T) {
+	dir := newRepo(t, map[string]string{"a.txt": "a\n"})
+	runGit(t, dir, "tag", "-a", "v1", "-m", "v1")
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	commitFile(t, dir, "b.txt", "b\n")
+	commitFile(t, dir, "c.txt", "c\n")
+	runGit(t, dir, "checkout", "-q", "main")
+	commitFile(t, dir, "a.txt", "a2\n")
+
+	refs, err := ListRefs(dir, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]int{"main": {0, 0}, "feature": {2, 1}}
+	if len(refs.Branches) != len(want) {
+		t.Fatalf("got branches %+v, want %v", refs.Branches, want)
+	}
+	for _, ref := range refs.Branches {
+		w, ok := want[ref.Name]
+		if !ok || ref.Ahead == nil || ref.Behind == nil || *ref.Ahead != w[0] || *ref.Behind != w[1] {
+			t.Errorf("%s: got ahead %v behind %v, want %v", ref.Name, ref.Ahead, ref.Behind, w)
+		}
+	}
+	if len(refs.Tags) != 1 || refs.Tags[0].Name != "v1" || refs.Tags[0].SHA == "" || refs.Tags[0].Ahead != nil {
+		t.Errorf("got tags %+v, want v1 peeled to its commit without counts", refs.Tags)
+	}
+}
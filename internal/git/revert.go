@@ -0,0 +1,121 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// RevertFile restores path in the worktree and index to its version at
+// baseCommit. Files that did not exist at baseCommit are removed. For renames,
+// oldPath is restored as well.
+func RevertFile(worktreePath, baseCommit, path, oldPath string) error {
+	for _, p := range []string{oldPath, path} {
+		if p == "" {
+			continue
+		}
+		if existsAt(worktreePath, baseCommit, p) {
+			args := append([]string{"-C", worktreePath, "checkout", baseCommit}, pathspecArgs([]string{p})...)
+			if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+				return fmt.Errorf("git checkout: %s: %w", out, err)
+			}
+			continue
+		}
+		args := append([]string{"-C", worktreePath, "rm", "-f", "--quiet", "--ignore-unmatch"}, pathspecArgs([]string{p})...)
+		cmd := exec.Command("git", args...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git rm: %s: %w", out, err)
+		}
+		// git rm leaves untracked files alone.
+		if err := os.Remove(filepath.Join(worktreePath, p)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// RevertHunk undoes one hunk of the base-to-worktree diff of path by applying
+// it in reverse to the worktree, and to the index if the whole hunk is staged
+// or committed. A hunk mixing staged and unstaged lines leaves the index
+// alone, so its staged lines stay staged. index counts hunks as in
+// DiffFile.Hunks. For renames, oldPath is diffed with path so the hunks match
+// the rename-paired diff, but only path's content changes.
+func RevertHunk(worktreePath, baseCommit, path, oldPath string, index int) error {
+	paths := []string{path}
+	if oldPath != "" && oldPath != path {
+		paths = append(paths, oldPath)
+	}
+	args := append([]string{"-C", worktreePath, "diff", "-M", baseCommit}, pathspecArgs(paths)...)
+	out, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return fmt.Errorf("git diff: %w", err)
+	}
+
+	header, hunks := splitHunks(string(out))
+	if index < 0 || index >= len(hunks) {
+		return fmt.Errorf("hunk %d out of range (file has %d)", index, len(hunks))
+	}
+	if len(paths) > 1 {
+		header = modificationHeader(header)
+	}
+	patch := header + hunks[index]
+
+	cmd := exec.Command("git", "-C", worktreePath, "apply", "-R", "--whitespace=nowarn", "-")
+	cmd.Stdin = strings.NewReader(patch)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git apply: %s: %w", out, err)
+	}
+	// Fails, as expected, unless the index has the hunk.
+	cmd = exec.Command("git", "-C", worktreePath, "apply", "-R", "--cached", "--whitespace=nowarn", "-")
+	cmd.Stdin = strings.NewReader(patch)
+	cmd.Run()
+	return nil
+}
+
+// modificationHeader replaces a rename's patch header with one that edits the
+// new path in place, so applying a hunk doesn't also undo the rename.
+func modificationHeader(header string) string {
+	var newName string
+	for _, line := range strings.Split(header, "\n") {
+		if name, ok := strings.CutPrefix(line, "+++ "); ok {
+			newName = name
+		}
+	}
+	// b/path, or "b/path" when git quotes it.
+	oldName := strings.Replace(newName, "b/", "a/", 1)
+	return fmt.Sprintf("diff --git %s %s\n--- %s\n+++ %s\n",
+		strings.TrimSuffix(oldName, "\t"), strings.TrimSuffix(newName, "\t"), oldName, newName)
+}
+
+// splitHunks splits a single-file patch into its header and raw hunks, each
+// keeping its "\ No newline at end of file" markers.
+func splitHunks(patch string) (string, []string) {
+	var header strings.Builder
+	var hunks []string
+	var cur *strings.Builder
+	for _, line := range strings.SplitAfter(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			if cur != nil {
+				hunks = append(hunks, cur.String())
+			}
+			cur = &strings.Builder{}
+		}
+		if cur != nil {
+			cur.WriteString(line)
+		} else {
+			header.WriteString(line)
+		}
+	}
+	if cur != nil {
+		hunks = append(hunks, cur.String())
+	}
+	return header.String(), hunks
+}
+
+func existsAt(worktreePath, commit, path string) bool {
+	return exec.Command("git", "-C", worktreePath, "cat-file", "-e", commit+":"+path).Run() == nil
+}
@@ -0,0 +1,39 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRevertFileLiteralPath(t *testing.T) {
+	wt := newRepo(t, map[string]string{"a.go": "a\n", "c.go": "c\n", ":(glob)c.go": "magic\n"})
+	base := runGit(t, wt, "rev-parse", "HEAD")
+	writeFiles(t, wt, map[string]string{
+		"a.go":        "a changed\n",
+		"c.go":        "c changed\n",
+		":(glob)c.go": "magic changed\n",
+		"*.go":        "new\n",
+	})
+
+	// As pathspecs, these would match a.go and c.go too.
+	for _, path := range []string{"*.go", ":(glob)c.go"} {
+		if err := RevertFile(wt, base, path, ""); err != nil {
+			t.Fatalf("RevertFile(%q): %v", path, err)
+		}
+	}
+
+	want := map[string]string{"a.go": "a changed\n", "c.go": "c changed\n", ":(glob)c.go": "magic\n"}
+	for name, content := range want {
+		got, err := os.ReadFile(filepath.Join(wt, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(wt, "*.go")); !os.IsNotExist(err) {
+		t.Errorf("*.go: err = %v, want it removed", err)
+	}
+}
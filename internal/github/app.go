@@ -0,0 +1,142 @@
+package github
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry an installation token is
+// replaced, so a token handed out is good for at least this long.
+const tokenRefreshMargin = 5 * time.Minute
+
+// tokenRequestTimeout bounds minting a token. Token holds its lock meanwhile,
+// so a hung request would block every caller.
+const tokenRequestTimeout = 30 * time.Second
+
+// AppTokenSource mints installation access tokens for a GitHub App and
+// caches each one until shortly before it expires (GitHub issues them for
+// an hour).
+type AppTokenSource struct {
+	APIURL         string
+	AppID          int64
+	InstallationID int64
+	HTTP           *http.Client // defaults to a client with tokenRequestTimeout
+
+	key *rsa.PrivateKey
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// NewAppTokenSource returns a token source for the app installation, signing
+// with the app's PEM-encoded private key.
+func NewAppTokenSource(apiURL string, appID, installationID int64, privateKeyPEM string) (*AppTokenSource, error) {
+	key, err := ParseAppPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &AppTokenSource{APIURL: apiURL, AppID: appID, InstallationID: installationID, key: key}, nil
+}
+
+// ParseAppPrivateKey parses a GitHub App private key, as downloaded from
+// GitHub (PKCS#1) or converted to PKCS#8.
+func ParseAppPrivateKey(privateKeyPEM string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKeyPEM))
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// Token returns a valid installation token, minting a new one if the cached
+// token is missing or about to expire.
+func (s *AppTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Until(s.expires) > tokenRefreshMargin {
+		return s.token, nil
+	}
+
+	jwt, err := s.appJWT(time.Now())
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	c := NewClient(s.APIURL, jwt)
+	c.HTTP = s.HTTP
+	if c.HTTP == nil {
+		c.HTTP = &http.Client{Timeout: tokenRequestTimeout}
+	}
+	if err := c.do("POST", fmt.Sprintf("/app/installations/%d/access_tokens", s.InstallationID), nil, &resp); err != nil {
+		return "", fmt.Errorf("minting installation token: %w", err)
+	}
+	s.token, s.expires = resp.Token, resp.ExpiresAt
+	return s.token, nil
+}
+
+// appJWT returns the short-lived JWT that authenticates as the app itself.
+func (s *AppTokenSource) appJWT(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(), // allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.AppID,
+	})
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign app JWT: %w", err)
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// ListInstallationRepos returns the repositories an app installation can
+// access. Installation tokens can't use the /user endpoints of ListAllRepos.
+func (c *Client) ListInstallationRepos() ([]Repo, error) {
+	var all []ghRepo
+	for page := 1; ; page++ {
+		var resp struct {
+			Repositories []ghRepo `json:"repositories"`
+		}
+		if err := c.get(fmt.Sprintf("/installation/repositories?per_page=100&page=%d", page), &resp); err != nil {
+			return nil, fmt.Errorf("listing installation repos: %w", err)
+		}
+		all = append(all, resp.Repositories...)
+		if len(resp.Repositories) < 100 {
+			break
+		}
+	}
+	return convertRepos(all), nil
+}
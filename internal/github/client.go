@@ -1,12 +1,32 @@
 package github
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
+// DefaultAPIURL is the API root of github.com.
+const DefaultAPIURL = "https://api.github.com"
+
+// Client calls the REST API of github.com or a GitHub Enterprise Server.
+type Client struct {
+	APIURL string       // API root, e.g. https://ghe.example.com/api/v3
+	Token  string       // sent as a bearer token
+	HTTP   *http.Client // defaults to http.DefaultClient
+}
+
+// NewClient returns a client for the API at apiURL, or github.com if empty.
+func NewClient(apiURL, token string) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{APIURL: strings.TrimRight(apiURL, "/"), Token: token}
+}
+
 type Repo struct {
 	FullName      string `json:"full_name"`
 	HTMLURL       string `json:"html_url"`
@@ -52,36 +72,66 @@ func convertRepos(ghRepos []ghRepo) []Repo {
 	return repos
 }
 
-func ghRequest(pat, url string, target interface{}) error {
-	req, err := http.NewRequest("GET", url, nil)
+func (c *Client) get(path string, target interface{}) error {
+	return c.do("GET", path, nil, target)
+}
+
+// do sends a request for path, relative to the API root, with an optional
+// JSON payload and decodes a 2xx response into target.
+func (c *Client) do(method, path string, payload, target interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.APIURL+path, reqBody)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+pat)
+	req.Header.Set("Authorization", "Bearer "+c.Token)
 	req.Header.Set("Accept", "application/vnd.github+json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	httpClient := c.HTTP
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("github API request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("github API error %d: %s", resp.StatusCode, string(body))
+		return &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return json.NewDecoder(resp.Body).Decode(target)
 }
 
+// APIError is a non-2xx response from the GitHub API.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github API error %d: %s", e.StatusCode, e.Body)
+}
+
 // paginateRepos fetches all pages from a paginated GitHub repos endpoint.
-func paginateRepos(pat, baseURL string) ([]Repo, error) {
+func (c *Client) paginateRepos(path string) ([]Repo, error) {
 	var all []ghRepo
 	page := 1
 	for {
-		url := fmt.Sprintf("%s&page=%d", baseURL, page)
 		var repos []ghRepo
-		if err := ghRequest(pat, url, &repos); err != nil {
+		if err := c.get(fmt.Sprintf("%s&page=%d", path, page), &repos); err != nil {
 			return nil, err
 		}
 		all = append(all, repos...)
@@ -95,16 +145,16 @@ func paginateRepos(pat, baseURL string) ([]Repo, error) {
 
 // ListAllRepos returns all repos the user has access to: their own repos
 // plus repos from all orgs they belong to, fully paginated and deduplicated.
-func ListAllRepos(pat string) ([]Repo, error) {
+func (c *Client) ListAllRepos() ([]Repo, error) {
 	// Fetch all user repos (owned, collaborator, org member)
-	allRepos, err := paginateRepos(pat, "https://api.github.com/user/repos?per_page=100&sort=updated&type=all")
+	allRepos, err := c.paginateRepos("/user/repos?per_page=100&sort=updated&type=all")
 	if err != nil {
 		return nil, fmt.Errorf("listing user repos: %w", err)
 	}
 
 	// Fetch user's orgs
 	var orgs []ghOrg
-	if err := ghRequest(pat, "https://api.github.com/user/orgs?per_page=100", &orgs); err != nil {
+	if err := c.get("/user/orgs?per_page=100", &orgs); err != nil {
 		// Non-fatal: we still have user repos
 		return allRepos, nil
 	}
@@ -116,7 +166,7 @@ func ListAllRepos(pat string) ([]Repo, error) {
 	}
 
 	for _, org := range orgs {
-		orgRepos, err := paginateRepos(pat, fmt.Sprintf("https://api.github.com/orgs/%s/repos?per_page=100&sort=updated", org.Login))
+		orgRepos, err := c.paginateRepos(fmt.Sprintf("/orgs/%s/repos?per_page=100&sort=updated", org.Login))
 		if err != nil {
 			continue // skip orgs that fail (permissions, etc.)
 		}
@@ -130,3 +180,12 @@ func ListAllRepos(pat string) ([]Repo, error) {
 
 	return allRepos, nil
 }
+
+// GetRepo returns a single repository.
+func (c *Client) GetRepo(owner, name string) (*Repo, error) {
+	var repo ghRepo
+	if err := c.get(fmt.Sprintf("/repos/%s/%s", owner, name), &repo); err != nil {
+		return nil, fmt.Errorf("getting repo: %w", err)
+	}
+	return &convertRepos([]ghRepo{repo})[0], nil
+}
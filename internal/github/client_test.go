@@ -0,0 +1,178 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newTestClient starts a stub API server with handler and returns a client
+// pointed at it under apiPath, e.g. "" for github.com or "/api/v3" for GitHub
+// Enterprise Server.
+func newTestClient(t *testing.T, apiPath string, handler http.Handler) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL+apiPath, "test-token")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// repoPage returns n repos named prefix-<first>...prefix-<first+n-1>.
+func repoPage(owner, prefix string, first, n int) []ghRepo {
+	repos := make([]ghRepo, n)
+	for i := range repos {
+		name := fmt.Sprintf("%s-%d", prefix, first+i)
+		repos[i].Name = name
+		repos[i].FullName = owner + "/" + name
+		repos[i].Owner.Login = owner
+	}
+	return repos
+}
+
+func TestListAllReposPaginates(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user/repos", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch page, _ := strconv.Atoi(r.URL.Query().Get("page")); page {
+		case 1:
+			writeJSON(w, http.StatusOK, repoPage("me", "repo", 0, 100))
+		case 2:
+			// The org repo also shows up here and must not be listed twice.
+			writeJSON(w, http.StatusOK, append(repoPage("me", "repo", 100, 1), repoPage("acme", "shared", 0, 1)...))
+		default:
+			t.Errorf("unexpected page %d", page)
+			writeJSON(w, http.StatusOK, []ghRepo{})
+		}
+	})
+	mux.HandleFunc("GET /user/orgs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []ghOrg{{Login: "acme"}, {Login: "private"}})
+	})
+	mux.HandleFunc("GET /orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, repoPage("acme", "shared", 0, 2))
+	})
+	mux.HandleFunc("GET /orgs/private/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"message": "forbidden"})
+	})
+
+	repos, err := newTestClient(t, "", mux).ListAllRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 103 {
+		t.Fatalf("got %d repos, want 103", len(repos))
+	}
+	if last := repos[len(repos)-1]; last.FullName != "acme/shared-1" || last.Owner != "acme" {
+		t.Errorf("last repo = %+v, want acme/shared-1", last)
+	}
+}
+
+func TestCreatePullRequestReturnsExisting(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		var pr NewPullRequest
+		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil || pr.Head != "feature" {
+			t.Errorf("request body = %+v, %v", pr, err)
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "A pull request already exists"})
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("head") != "acme:feature" || q.Get("state") != "open" {
+			t.Errorf("query = %v", q)
+		}
+		writeJSON(w, http.StatusOK, []PullRequest{{Number: 7, HTMLURL: "https://github.com/acme/widgets/pull/7", State: "open"}})
+	})
+
+	pr, err := newTestClient(t, "", mux).CreatePullRequest("acme", "widgets", NewPullRequest{Title: "t", Head: "feature", Base: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.Number != 7 {
+		t.Errorf("got PR #%d, want #7", pr.Number)
+	}
+}
+
+func TestCreatePullRequestReportsValidationError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"message": "No commits between main and feature"})
+	})
+	mux.HandleFunc("GET /repos/acme/widgets/pulls", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []PullRequest{})
+	})
+
+	_, err := newTestClient(t, "", mux).CreatePullRequest("acme", "widgets", NewPullRequest{Head: "feature", Base: "main"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("err = %v, want a 422 APIError", err)
+	}
+}
+
+func TestEnterpriseAPIPaths(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/acme/widgets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ghRepo{FullName: "acme/widgets", Name: "widgets", DefaultBranch: "trunk"})
+	})
+	mux.HandleFunc("GET /api/v3/repos/acme/widgets/issues/3", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Issue{Number: 3, Title: "Broken"})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request outside /api/v3: %s", r.URL.Path)
+		http.NotFound(w, r)
+	})
+
+	// A trailing slash on the API root must not double up.
+	c := newTestClient(t, "/api/v3/", mux)
+	repo, err := c.GetRepo("acme", "widgets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.DefaultBranch != "trunk" {
+		t.Errorf("default branch = %q, want trunk", repo.DefaultBranch)
+	}
+	issue, err := c.GetIssue("acme", "widgets", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issue.Title != "Broken" {
+		t.Errorf("issue title = %q", issue.Title)
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusUnauthorized, `{"message":"Bad credentials"}`},
+		{http.StatusNotFound, `{"message":"Not Found"}`},
+		{http.StatusBadGateway, "upstream unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			c := newTestClient(t, "", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			_, err := c.GetPullRequest("acme", "widgets", 1)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Body != tt.body {
+				t.Errorf("APIError = %d %q, want %d %q", apiErr.StatusCode, apiErr.Body, tt.status, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package github
+
+import "fmt"
+
+// Issue is the subset of a GitHub issue needed to start a session from it.
+type Issue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	// Set when the issue is a pull request; GitHub serves both from the
+	// issues API.
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+// GetIssue returns issue number of owner/name.
+func (c *Client) GetIssue(owner, name string, number int) (*Issue, error) {
+	var issue Issue
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/issues/%d", owner, name, number), &issue); err != nil {
+		return nil, fmt.Errorf("getting issue #%d: %w", number, err)
+	}
+	return &issue, nil
+}
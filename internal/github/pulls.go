@@ -0,0 +1,79 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// PullRequest is the subset of a GitHub pull request we keep track of.
+type PullRequest struct {
+	Number  int             `json:"number"`
+	HTMLURL string          `json:"html_url"`
+	State   string          `json:"state"`
+	Draft   bool            `json:"draft"`
+	Title   string          `json:"title,omitempty"`
+	Head    *PullRequestRef `json:"head,omitempty"`
+	Base    *PullRequestRef `json:"base,omitempty"`
+}
+
+// PullRequestRef is the head or base branch of a pull request.
+type PullRequestRef struct {
+	Ref  string `json:"ref"`
+	SHA  string `json:"sha"`
+	Repo *struct {
+		FullName string `json:"full_name"`
+	} `json:"repo"` // nil if the head repository was deleted
+}
+
+// NewPullRequest describes a pull request to open.
+type NewPullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Body  string `json:"body,omitempty"`
+	Draft bool   `json:"draft,omitempty"`
+}
+
+// CreatePullRequest opens a pull request on owner/name. If an open pull
+// request already exists for the same head branch, that one is returned.
+func (c *Client) CreatePullRequest(owner, name string, pr NewPullRequest) (*PullRequest, error) {
+	var created PullRequest
+	err := c.do("POST", fmt.Sprintf("/repos/%s/%s/pulls", owner, name), pr, &created)
+	if err == nil {
+		return &created, nil
+	}
+
+	// GitHub answers 422 when a PR for this head already exists.
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity {
+		if existing, findErr := c.FindPullRequest(owner, name, pr.Head); findErr == nil && existing != nil {
+			return existing, nil
+		}
+	}
+	return nil, fmt.Errorf("creating pull request: %w", err)
+}
+
+// FindPullRequest returns the open pull request whose head is branch, or nil
+// if there is none.
+func (c *Client) FindPullRequest(owner, name, branch string) (*PullRequest, error) {
+	q := url.Values{"head": {owner + ":" + branch}, "state": {"open"}}
+	var prs []PullRequest
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/pulls?%s", owner, name, q.Encode()), &prs); err != nil {
+		return nil, err
+	}
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return &prs[0], nil
+}
+
+// GetPullRequest returns pull request number of owner/name.
+func (c *Client) GetPullRequest(owner, name string, number int) (*PullRequest, error) {
+	var pr PullRequest
+	if err := c.get(fmt.Sprintf("/repos/%s/%s/pulls/%d", owner, name, number), &pr); err != nil {
+		return nil, fmt.Errorf("getting pull request #%d: %w", number, err)
+	}
+	return &pr, nil
+}
@@ -15,11 +15,32 @@ type Repository struct {
 	Name          string     `json:"name"`
 	LocalPath     string     `json:"local_path"`
 	CloneStatus   string     `json:"clone_status"`
+	CloneError    string     `json:"clone_error"`
 	DefaultBranch string     `json:"default_branch"`
 	LastSynced    *time.Time `json:"last_synced"`
 	CreatedAt     time.Time  `json:"created_at"`
 	SourcePath    *string    `json:"source_path"`
 	RepoType      string     `json:"repo_type"`
+	Host          string     `json:"host"`
+	CloneURL      string     `json:"clone_url"`
+	LastSyncError string     `json:"last_sync_error"`
+	Syncing       bool       `json:"syncing"`
+	NextSync      *time.Time `json:"next_sync"`
+}
+
+// Host is a git server that repositories are cloned from.
+type Host struct {
+	Name     string `json:"name"`               // hostname, e.g. git.example.com
+	Kind     string `json:"kind"`               // github, gitlab, gitea or git
+	WebURL   string `json:"web_url"`            // e.g. https://git.example.com
+	APIURL   string `json:"api_url,omitempty"`  // REST API root
+	Username string `json:"username,omitempty"` // HTTPS username sent with the token
+	Token    string `json:"token,omitempty"`
+
+	// GitHub App installation used instead of Token on github hosts.
+	AppID          int64  `json:"app_id,omitempty"`
+	InstallationID int64  `json:"installation_id,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"` // PEM
 }
 
 type Session struct {
@@ -33,6 +54,31 @@ type Session struct {
 	CreatedAt    time.Time `json:"created_at"`
 	SourceBranch string    `json:"source_branch"`
 	BaseCommit   string    `json:"base_commit"`
+	Resumable    bool      `json:"resumable"`
+	PRNumber     *int      `json:"pr_number"`
+	PRURL        *string   `json:"pr_url"`
+	IssueNumber  *int      `json:"issue_number"`
+	IssueURL     *string   `json:"issue_url"`
+}
+
+type ReviewComment struct {
+	ID          int64     `json:"id"`
+	SessionID   string    `json:"session_id"`
+	Path        string    `json:"path"`
+	Side        string    `json:"side"` // "old" or "new"
+	Line        int       `json:"line"`
+	LineContent string    `json:"line_content"`
+	Body        string    `json:"body"`
+	Outdated    bool      `json:"outdated"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// EnvVar is one entry in a repository's environment profile.
+type EnvVar struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Secret bool   `json:"secret,omitempty"`
 }
 
 type CLIStatus struct {
@@ -41,6 +87,7 @@ type CLIStatus struct {
 	Authed    bool   `json:"authed"`
 	Path      string `json:"path,omitempty"`
 	Command   string `json:"command,omitempty"`
+	Version   string `json:"version,omitempty"`
 }
 
 type HealthResponse struct {
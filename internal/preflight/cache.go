@@ -0,0 +1,91 @@
+package preflight
+
+import (
+	"database/sql"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/peterje/superposition/internal/agents"
+	"github.com/peterje/superposition/internal/models"
+)
+
+// agentCacheTTL is how long cached agent checks are served before a
+// background refresh is started.
+const agentCacheTTL = time.Minute
+
+// AgentCache serves agent checks from memory so callers such as the health
+// endpoint never wait on probes. Stale results are refreshed in the
+// background.
+type AgentCache struct {
+	db *sql.DB
+
+	mu         sync.Mutex
+	clis       []models.CLIStatus
+	checked    time.Time
+	configMod  time.Time // agents.json's modification time when checked
+	stale      bool
+	refreshing bool
+}
+
+// NewAgentCache returns a cache seeded with the checks run at startup.
+func NewAgentCache(db *sql.DB, clis []models.CLIStatus) *AgentCache {
+	return &AgentCache{db: db, clis: clis, checked: time.Now(), configMod: configModTime()}
+}
+
+// Get returns the cached checks, starting a refresh if they are older than
+// agentCacheTTL, were invalidated, or agents.json changed since.
+func (c *AgentCache) Get() []models.CLIStatus {
+	mod := configModTime()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.refreshing && (c.stale || !mod.Equal(c.configMod) || time.Since(c.checked) > agentCacheTTL) {
+		c.refreshing, c.stale = true, false
+		go c.refresh()
+	}
+	return c.clis
+}
+
+// Invalidate makes the next Get refresh the checks, e.g. because the agent
+// settings changed.
+func (c *AgentCache) Invalidate() {
+	c.mu.Lock()
+	c.stale = true
+	c.mu.Unlock()
+}
+
+// Check runs the checks now and caches the result.
+func (c *AgentCache) Check() []models.CLIStatus {
+	c.mu.Lock()
+	c.stale = false
+	c.mu.Unlock()
+	mod := configModTime()
+	clis := CheckAgents(c.db)
+	c.mu.Lock()
+	c.clis, c.checked, c.configMod = clis, time.Now(), mod
+	c.mu.Unlock()
+	return clis
+}
+
+func (c *AgentCache) refresh() {
+	mod := configModTime()
+	clis := CheckAgents(c.db)
+	c.mu.Lock()
+	c.clis, c.checked, c.configMod, c.refreshing = clis, time.Now(), mod, false
+	c.mu.Unlock()
+}
+
+// configModTime returns when agents.json was last modified, or the zero time
+// if there is none. A stat is cheap enough to do on every Get, unlike
+// loading the registry.
+func configModTime() time.Time {
+	path, err := agents.ConfigPath()
+	if err != nil {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -1,21 +1,24 @@
 package preflight
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/peterje/superposition/internal/agents"
 	"github.com/peterje/superposition/internal/models"
 )
 
+// probeTimeout bounds how long an auth check or version probe may run.
+const probeTimeout = 5 * time.Second
+
 func CheckAll(db *sql.DB) ([]models.CLIStatus, bool) {
 	gitOk := checkGit()
-	clis := []models.CLIStatus{
-		checkCLI("claude", db),
-		checkCLI("codex", db),
-		checkCLI("gemini", db),
-	}
+	clis := CheckAgents(db)
 
 	if !gitOk {
 		fmt.Println("⚠ git is not installed. Please install git to use Superposition.")
@@ -24,10 +27,17 @@ func CheckAll(db *sql.DB) ([]models.CLIStatus, bool) {
 		if !cli.Installed {
 			fmt.Printf("⚠ %s is not installed. Install it to use %s sessions.\n", cli.Name, cli.Name)
 		} else {
+			found := cli.Path
+			if cli.Version != "" {
+				found = cli.Path + ", " + cli.Version
+			}
 			if cli.Command != "" {
-				fmt.Printf("✓ %s found (%s) [override: %s]\n", cli.Name, cli.Path, cli.Command)
+				fmt.Printf("✓ %s found (%s) [override: %s]\n", cli.Name, found, cli.Command)
 			} else {
-				fmt.Printf("✓ %s found (%s)\n", cli.Name, cli.Path)
+				fmt.Printf("✓ %s found (%s)\n", cli.Name, found)
+			}
+			if !cli.Authed {
+				fmt.Printf("⚠ %s is not authenticated.\n", cli.Name)
 			}
 		}
 	}
@@ -35,31 +45,54 @@ func CheckAll(db *sql.DB) ([]models.CLIStatus, bool) {
 	return clis, gitOk
 }
 
+// CheckAgents checks every registered agent concurrently without printing.
+func CheckAgents(db *sql.DB) []models.CLIStatus {
+	registry := agents.Load(db)
+	clis := make([]models.CLIStatus, len(registry))
+
+	var wg sync.WaitGroup
+	for i, agent := range registry {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clis[i] = checkAgent(agent, agents.CommandOverride(db, agent.Name))
+		}()
+	}
+	wg.Wait()
+	return clis
+}
+
 func checkGit() bool {
 	_, err := exec.LookPath("git")
 	return err == nil
 }
 
-func checkCLI(name string, db *sql.DB) models.CLIStatus {
-	// Check for a command override in settings
-	var override string
-	if db != nil {
-		var val string
-		err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, "cli_command."+name).Scan(&val)
-		if err == nil && val != "" {
-			override = val
-		}
+func checkAgent(agent agents.Agent, override string) models.CLIStatus {
+	path, err := exec.LookPath(agent.Binary)
+	if err != nil {
+		return models.CLIStatus{Name: agent.Name, Installed: false, Command: override}
 	}
 
-	// Determine which binary to look up
-	binary := name
-	if override != "" {
-		binary = strings.Fields(override)[0]
+	status := models.CLIStatus{Name: agent.Name, Installed: true, Authed: true, Path: path, Command: override}
+	if len(agent.AuthCheckArgs) > 0 {
+		_, err := runProbe(path, agent, agent.AuthCheckArgs)
+		status.Authed = err == nil
 	}
-
-	path, err := exec.LookPath(binary)
-	if err != nil {
-		return models.CLIStatus{Name: name, Installed: false, Command: override}
+	if len(agent.VersionArgs) > 0 {
+		if out, err := runProbe(path, agent, agent.VersionArgs); err == nil {
+			status.Version, _, _ = strings.Cut(strings.TrimSpace(out), "\n")
+		}
 	}
-	return models.CLIStatus{Name: name, Installed: true, Authed: true, Path: path, Command: override}
+	return status
+}
+
+// runProbe runs the agent binary with args and the agent's environment.
+func runProbe(path string, agent agents.Agent, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = append(cmd.Environ(), agent.Environ()...)
+	out, err := cmd.Output()
+	return string(out), err
 }
@@ -10,7 +10,10 @@ type SessionHandle interface {
 
 // SessionManager manages PTY session lifecycles.
 type SessionManager interface {
-	Start(id, cliType, workDir string) (SessionHandle, int /* pid */, error)
+	// Start launches argv in workDir. env holds extra KEY=VALUE pairs
+	// layered on top of the server's environment. A non-empty stdin is piped
+	// to the process in place of terminal input.
+	Start(id string, argv []string, workDir string, env []string, stdin string) (SessionHandle, int /* pid */, error)
 	Stop(id string) error
 	Get(id string) SessionHandle
 	Resize(id string, rows, cols uint16) error
@@ -2,6 +2,7 @@ package pty
 
 import (
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"strings"
@@ -9,10 +10,24 @@ import (
 	"syscall"
 
 	"github.com/creack/pty"
+	"github.com/peterje/superposition/internal/transcript"
 )
 
 const replayBufSize = 100 * 1024 // 100KB replay buffer
 
+// StartCommand starts cmd on a new 120x40 terminal and returns its master
+// side. If stdin is non-empty the process reads it from a pipe, which unlike
+// a terminal line has no length limit, and the terminal only carries output.
+func StartCommand(cmd *exec.Cmd, stdin string) (*os.File, error) {
+	size := &pty.Winsize{Rows: 40, Cols: 120}
+	if stdin == "" {
+		return pty.StartWithSize(cmd, size)
+	}
+	cmd.Stdin = strings.NewReader(stdin)
+	// Ctty is a child fd; stdout is the terminal, stdin no longer is.
+	return pty.StartWithAttrs(cmd, size, &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 1})
+}
+
 type Session struct {
 	ID  string
 	Cmd *exec.Cmd
@@ -20,6 +35,11 @@ type Session struct {
 
 	done chan struct{}
 
+	// transcript persists all PTY output to disk and recorder keeps a timed
+	// asciicast of output and resizes; each is nil if it couldn't be opened
+	transcript *transcript.Writer
+	recorder   *transcript.Recorder
+
 	mu      sync.Mutex
 	stopped bool
 
@@ -97,13 +117,22 @@ func NewManager() *Manager {
 	}
 }
 
-func (m *Manager) Start(id, cliType, workDir string) (SessionHandle, int, error) {
-	args := strings.Fields(cliType)
-	cmd := exec.Command(args[0], args[1:]...)
+func (m *Manager) Start(id string, argv []string, workDir string, env []string, stdin string) (SessionHandle, int, error) {
+	// A stopped session may be restarted under the same ID; a running one may not.
+	if old := m.getSession(id); old != nil {
+		old.mu.Lock()
+		running := !old.stopped
+		old.mu.Unlock()
+		if running {
+			return nil, 0, fmt.Errorf("session already running: %s", id)
+		}
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
 	cmd.Dir = workDir
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), env...)
 
-	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 40, Cols: 120})
+	ptmx, err := StartCommand(cmd, stdin)
 	if err != nil {
 		return nil, 0, fmt.Errorf("start pty: %w", err)
 	}
@@ -115,8 +144,18 @@ func (m *Manager) Start(id, cliType, workDir string) (SessionHandle, int, error)
 		done:        make(chan struct{}),
 		subscribers: make(map[chan []byte]struct{}),
 	}
+	if tw, err := transcript.NewWriter(id); err != nil {
+		log.Printf("pty: transcript disabled for %s: %v", id, err)
+	} else {
+		sess.transcript = tw
+	}
+	if rec, err := transcript.NewRecorder(id, 120, 40); err != nil {
+		log.Printf("pty: recording disabled for %s: %v", id, err)
+	} else {
+		sess.recorder = rec
+	}
 
-	// Read from PTY, fan out to replay buffer + subscribers
+	// Read from PTY, fan out to replay buffer + transcript + subscribers
 	go func() {
 		buf := make([]byte, 32*1024)
 		for {
@@ -125,12 +164,24 @@ func (m *Manager) Start(id, cliType, workDir string) (SessionHandle, int, error)
 				data := make([]byte, n)
 				copy(data, buf[:n])
 				sess.appendReplay(data)
+				if sess.transcript != nil {
+					sess.transcript.Write(data)
+				}
+				if sess.recorder != nil {
+					sess.recorder.Output(data)
+				}
 				sess.broadcast(data)
 			}
 			if err != nil {
 				break
 			}
 		}
+		if sess.transcript != nil {
+			sess.transcript.Close()
+		}
+		if sess.recorder != nil {
+			sess.recorder.Close()
+		}
 		// Close all subscriber channels
 		sess.subMu.Lock()
 		for ch := range sess.subscribers {
@@ -202,7 +253,13 @@ func (m *Manager) Resize(id string, rows, cols uint16) error {
 	if sess == nil {
 		return fmt.Errorf("session not found: %s", id)
 	}
-	return pty.Setsize(sess.PTY, &pty.Winsize{Rows: rows, Cols: cols})
+	if err := pty.Setsize(sess.PTY, &pty.Winsize{Rows: rows, Cols: cols}); err != nil {
+		return err
+	}
+	if sess.recorder != nil {
+		sess.recorder.Resize(cols, rows)
+	}
+	return nil
 }
 
 func (m *Manager) StopAll() {
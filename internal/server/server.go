@@ -4,27 +4,31 @@ import (
 	"database/sql"
 	"net/http"
 
+	"github.com/peterje/superposition/internal/agents"
 	"github.com/peterje/superposition/internal/api"
 	"github.com/peterje/superposition/internal/models"
+	"github.com/peterje/superposition/internal/preflight"
 	ptymgr "github.com/peterje/superposition/internal/pty"
 	"github.com/peterje/superposition/internal/ws"
 )
 
 type Server struct {
-	mux       *http.ServeMux
-	db        *sql.DB
-	cliStatus []models.CLIStatus
-	gitOk     bool
-	PtyMgr    ptymgr.SessionManager
+	mux    *http.ServeMux
+	db     *sql.DB
+	gitOk  bool
+	agents *preflight.AgentCache
+	PtyMgr ptymgr.SessionManager
+	Syncer *api.RepoSyncer
 }
 
-func New(db *sql.DB, cliStatus []models.CLIStatus, gitOk bool, spaHandler http.Handler, ptyMgr ptymgr.SessionManager) *Server {
+func New(db *sql.DB, gitOk bool, agents *preflight.AgentCache, spaHandler http.Handler, ptyMgr ptymgr.SessionManager) *Server {
 	s := &Server{
-		mux:       http.NewServeMux(),
-		db:        db,
-		cliStatus: cliStatus,
-		gitOk:     gitOk,
-		PtyMgr:    ptyMgr,
+		mux:    http.NewServeMux(),
+		db:     db,
+		gitOk:  gitOk,
+		agents: agents,
+		PtyMgr: ptyMgr,
+		Syncer: api.NewRepoSyncer(db),
 	}
 	s.routes(spaHandler)
 	return s
@@ -35,13 +39,19 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) routes(spaHandler http.Handler) {
-	settings := api.NewSettingsHandler(s.db)
-	repos := api.NewReposHandler(s.db)
+	settings := api.NewSettingsHandler(s.db, func(key string) {
+		if agents.IsSetting(key) {
+			s.agents.Invalidate()
+		}
+	})
+	repos := api.NewReposHandler(s.db, s.Syncer)
 	sessions := api.NewSessionsHandler(s.db, s.PtyMgr)
+	comments := api.NewCommentsHandler(s.db)
 	wsHandler := ws.NewHandler(s.PtyMgr)
 
 	// Health
 	s.mux.HandleFunc("GET /api/health", s.handleHealth)
+	s.mux.HandleFunc("GET /api/health/agents", s.handleHealthAgents)
 
 	// Settings
 	s.mux.HandleFunc("GET /api/settings", settings.ServeHTTP)
@@ -58,15 +68,40 @@ func (s *Server) routes(spaHandler http.Handler) {
 	s.mux.HandleFunc("DELETE /api/repos/{id}", repos.HandleDelete)
 	s.mux.HandleFunc("POST /api/repos/{id}/sync", repos.HandleSync)
 	s.mux.HandleFunc("GET /api/repos/{id}/branches", repos.HandleBranches)
+	s.mux.HandleFunc("GET /api/repos/{id}/refs", repos.HandleRefs)
+	s.mux.HandleFunc("GET /api/repos/{id}/progress", repos.HandleProgress)
+	s.mux.HandleFunc("GET /api/repos/{id}/deploy-key", repos.HandleDeployKey)
+	s.mux.HandleFunc("POST /api/repos/{id}/deploy-key", repos.HandleGenerateDeployKey)
+	s.mux.HandleFunc("DELETE /api/repos/{id}/deploy-key", repos.HandleDeleteDeployKey)
 
 	// Sessions
 	s.mux.HandleFunc("GET /api/sessions", sessions.HandleList)
 	s.mux.HandleFunc("POST /api/sessions", sessions.HandleCreate)
 	s.mux.HandleFunc("GET /api/sessions/{id}/replay", sessions.HandleReplay)
+	s.mux.HandleFunc("GET /api/sessions/{id}/transcript", sessions.HandleTranscript)
+	s.mux.HandleFunc("GET /api/sessions/{id}/recording.cast", sessions.HandleRecording)
 	s.mux.HandleFunc("GET /api/sessions/{id}/diff", sessions.HandleDiff)
 	s.mux.HandleFunc("POST /api/sessions/{id}/input", sessions.HandleInput)
+	s.mux.HandleFunc("POST /api/sessions/{id}/restart", sessions.HandleRestart)
+	s.mux.HandleFunc("GET /api/sessions/{id}/credentials", sessions.HandleCredentials)
+	s.mux.HandleFunc("POST /api/sessions/{id}/pull-request", sessions.HandlePullRequest)
+	s.mux.HandleFunc("GET /api/sessions/{id}/commits", sessions.HandleCommits)
+	s.mux.HandleFunc("GET /api/sessions/{id}/commits/{sha}/diff", sessions.HandleCommitDiff)
+	s.mux.HandleFunc("GET /api/sessions/{id}/file", sessions.HandleFile)
+	s.mux.HandleFunc("GET /api/sessions/{id}/diff/file", sessions.HandleDiffFile)
+	s.mux.HandleFunc("POST /api/sessions/{id}/diff/revert", sessions.HandleRevert)
+	s.mux.HandleFunc("GET /api/sessions/{id}/status", sessions.HandleStatus)
+	s.mux.HandleFunc("POST /api/sessions/{id}/commit", sessions.HandleCommit)
+	s.mux.HandleFunc("POST /api/sessions/{id}/merge", sessions.HandleMerge)
+	s.mux.HandleFunc("POST /api/sessions/{id}/rebase", sessions.HandleRebase)
 	s.mux.HandleFunc("DELETE /api/sessions/{id}", sessions.HandleDelete)
 
+	// Review comments
+	s.mux.HandleFunc("GET /api/sessions/{id}/comments", comments.HandleList)
+	s.mux.HandleFunc("POST /api/sessions/{id}/comments", comments.HandleCreate)
+	s.mux.HandleFunc("PUT /api/sessions/{id}/comments/{commentId}", comments.HandleUpdate)
+	s.mux.HandleFunc("DELETE /api/sessions/{id}/comments/{commentId}", comments.HandleDelete)
+
 	// WebSocket
 	s.mux.Handle("GET /ws/session/{id}", wsHandler)
 
@@ -75,10 +110,18 @@ func (s *Server) routes(spaHandler http.Handler) {
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	// Served from the cache: probes can take seconds, and liveness checks
+	// hit this endpoint with a short timeout.
 	resp := models.HealthResponse{
 		Status: "ok",
-		CLIs:   s.cliStatus,
+		CLIs:   s.agents.Get(),
 		Git:    s.gitOk,
 	}
 	api.WriteJSON(w, http.StatusOK, resp)
 }
+
+// handleHealthAgents re-runs the agent checks now instead of serving the
+// cached result.
+func (s *Server) handleHealthAgents(w http.ResponseWriter, _ *http.Request) {
+	api.WriteJSON(w, http.StatusOK, s.agents.Check())
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"unicode"
 
 	ptymgr "github.com/peterje/superposition/internal/pty"
 )
@@ -29,6 +31,8 @@ type Client struct {
 
 	reqCounter atomic.Uint64
 	closed     chan struct{}
+
+	version int // shepherd protocol version, set by Ping
 }
 
 // NewClient connects to the shepherd at the given socket path.
@@ -71,9 +75,16 @@ func (c *Client) Ping() error {
 	if resp.Event != evtPong {
 		return fmt.Errorf("unexpected response: %s", resp.Event)
 	}
+	c.version = max(resp.Version, 1)
 	return nil
 }
 
+// Outdated reports whether the shepherd, as of the last Ping, speaks an older
+// protocol than this client, e.g. because it survived an upgrade.
+func (c *Client) Outdated() bool {
+	return c.version < protocolVersion
+}
+
 // ListSessions returns all active session IDs in the shepherd.
 func (c *Client) ListSessions() ([]string, error) {
 	resp, err := c.sendRequest(Request{Command: cmdList})
@@ -84,17 +95,34 @@ func (c *Client) ListSessions() ([]string, error) {
 }
 
 // Start implements ptymgr.SessionManager.
-func (c *Client) Start(id, cliType, workDir string) (ptymgr.SessionHandle, int, error) {
-	// Pre-create done channel so we don't miss exit events
+func (c *Client) Start(id string, argv []string, workDir string, env []string, stdin string) (ptymgr.SessionHandle, int, error) {
+	// An old shepherd silently drops fields it doesn't know: it would split
+	// the joined command line on whitespace and start the process without
+	// its env or stdin.
+	if c.version < protocolArgs && (len(env) > 0 || !splitsCleanly(argv)) {
+		return nil, 0, fmt.Errorf("shepherd is too old to pass this command or its env; restart it after stopping its sessions")
+	}
+	if c.version < protocolStdin && stdin != "" {
+		return nil, 0, fmt.Errorf("shepherd is too old to pass stdin; restart it after stopping its sessions")
+	}
+
+	// Pre-create done channel so we don't miss exit events. A restarted
+	// session reuses its ID, so drop any subscription state from the
+	// previous run; the shepherd needs a fresh cmdSubscribe.
 	c.sessionMu.Lock()
 	c.sessionDone[id] = make(chan struct{})
+	delete(c.sessionSubs, id)
+	delete(c.shepherdSubbed, id)
 	c.sessionMu.Unlock()
 
 	resp, err := c.sendRequest(Request{
 		Command:   cmdStart,
 		SessionID: id,
-		CLIType:   cliType,
+		CLIType:   strings.Join(argv, " "),
+		Args:      argv,
 		WorkDir:   workDir,
+		Env:       env,
+		Stdin:     stdin,
 	})
 	if err != nil {
 		c.sessionMu.Lock()
@@ -348,6 +376,17 @@ func (c *Client) Done(sessionID string) <-chan struct{} {
 	return ch
 }
 
+// splitsCleanly reports whether joining argv with spaces and splitting it on
+// whitespace gives argv back.
+func splitsCleanly(argv []string) bool {
+	for _, arg := range argv {
+		if arg == "" || strings.ContainsFunc(arg, unicode.IsSpace) {
+			return false
+		}
+	}
+	return true
+}
+
 // ProxySession implements ptymgr.SessionHandle by proxying to the shepherd.
 type ProxySession struct {
 	client    *Client
@@ -0,0 +1,49 @@
+package shepherd
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestStartRefusesOldShepherd checks that starts an old shepherd would
+// silently mangle fail before anything is sent to it.
+func TestStartRefusesOldShepherd(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		argv    []string
+		env     []string
+		stdin   string
+		refused bool
+	}{
+		{"v1 plain argv", 1, []string{"claude", "--verbose"}, nil, "", false},
+		{"v1 spaced argv", 1, []string{"claude", "fix the bug"}, nil, "", true},
+		{"v1 env", 1, []string{"claude"}, []string{"API_KEY=secret"}, "", true},
+		{"v1 stdin", 1, []string{"claude"}, nil, "prompt\n", true},
+		{"v2 stdin", 2, []string{"claude"}, nil, "prompt\n", true},
+		{"v3 everything", 3, []string{"claude", "fix the bug"}, []string{"API_KEY=secret"}, "prompt\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The shepherd end is closed, so a start that isn't refused
+			// fails on sending.
+			conn, shepherdEnd := net.Pipe()
+			shepherdEnd.Close()
+			c := &Client{
+				conn:        conn,
+				pending:     make(map[string]chan Response),
+				sessionDone: make(map[string]chan struct{}),
+				closed:      make(chan struct{}),
+				version:     tt.version,
+			}
+			_, _, err := c.Start("s1", tt.argv, "/tmp", tt.env, tt.stdin)
+			if err == nil {
+				t.Fatal("Start succeeded without a shepherd")
+			}
+			if refused := strings.Contains(err.Error(), "too old"); refused != tt.refused {
+				t.Errorf("err = %v, refused = %v, want %v", err, refused, tt.refused)
+			}
+		})
+	}
+}
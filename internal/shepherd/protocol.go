@@ -14,6 +14,16 @@ const (
 	frameInput   byte = 0x03 // PTY input data: sessionID + raw bytes
 )
 
+// Protocol versions. The shepherd reports its version in the pong to a ping;
+// shepherds that predate versioning report none and are version 1, which
+// ignore Args and Env and run CLIType split on whitespace.
+const (
+	protocolArgs  = 2 // Args and Env
+	protocolStdin = 3 // Stdin
+
+	protocolVersion = protocolStdin
+)
+
 // Command types for JSON control messages.
 const (
 	cmdStart     = "start"
@@ -44,9 +54,12 @@ type Request struct {
 	Command string `json:"command"` // cmdStart, cmdStop, etc.
 
 	// Start fields
-	SessionID string `json:"session_id,omitempty"`
-	CLIType   string `json:"cli_type,omitempty"`
-	WorkDir   string `json:"work_dir,omitempty"`
+	SessionID string   `json:"session_id,omitempty"`
+	CLIType   string   `json:"cli_type,omitempty"` // command line, split on whitespace if Args is empty
+	Args      []string `json:"args,omitempty"`
+	WorkDir   string   `json:"work_dir,omitempty"`
+	Env       []string `json:"env,omitempty"`   // extra KEY=VALUE pairs
+	Stdin     string   `json:"stdin,omitempty"` // piped to the process instead of the terminal
 
 	// Resize fields
 	Rows uint16 `json:"rows,omitempty"`
@@ -58,6 +71,9 @@ type Response struct {
 	ID    string `json:"id"`    // correlates with request ID
 	Event string `json:"event"` // evtStarted, evtError, etc.
 
+	// Pong response
+	Version int `json:"version,omitempty"`
+
 	// Start response
 	PID int `json:"pid,omitempty"`
 
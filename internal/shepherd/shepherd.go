@@ -14,12 +14,19 @@ import (
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/creack/pty"
+	ptymgr "github.com/peterje/superposition/internal/pty"
+	"github.com/peterje/superposition/internal/transcript"
 )
 
 const replayBufSize = 100 * 1024 // 100KB
 
+// stopGrace is how long a stopped session may take to exit after SIGTERM
+// before it is killed.
+const stopGrace = 5 * time.Second
+
 // session is a PTY session owned by the shepherd.
 type session struct {
 	id   string
@@ -27,6 +34,11 @@ type session struct {
 	ptmx *os.File
 	done chan struct{}
 
+	// transcript persists all PTY output to disk and recorder keeps a timed
+	// asciicast of output and resizes; each is nil if it couldn't be opened
+	transcript *transcript.Writer
+	recorder   *transcript.Recorder
+
 	mu      sync.Mutex
 	stopped bool
 
@@ -119,6 +131,37 @@ func SocketPath() (string, error) {
 	return filepath.Join(home, ".superposition", "shepherd.sock"), nil
 }
 
+// Stop shuts down the running shepherd, stopping its sessions, and waits up
+// to 5s for it to remove its socket.
+func Stop() error {
+	socketPath, err := SocketPath()
+	if err != nil {
+		return err
+	}
+	pidPath, err := PIDPath()
+	if err != nil {
+		return err
+	}
+	pidData, err := os.ReadFile(pidPath)
+	if err != nil {
+		return fmt.Errorf("read pid file: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(pidData)))
+	if err != nil {
+		return fmt.Errorf("parse pid file: %w", err)
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("signal shepherd: %w", err)
+	}
+	for i := 0; i < 100; i++ { // 100 * 50ms = 5s
+		if _, err := os.Stat(socketPath); os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("shepherd did not exit within 5s")
+}
+
 // PIDPath returns the path to the shepherd's PID file.
 func PIDPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -229,7 +272,7 @@ func (s *Shepherd) handleControl(cw *connWriter, payload []byte) {
 
 	switch req.Command {
 	case cmdPing:
-		s.sendResponse(cw, Response{ID: req.ID, Event: evtPong})
+		s.sendResponse(cw, Response{ID: req.ID, Event: evtPong, Version: protocolVersion})
 
 	case cmdStart:
 		s.handleStart(cw, req)
@@ -256,12 +299,23 @@ func (s *Shepherd) handleControl(cw *connWriter, payload []byte) {
 }
 
 func (s *Shepherd) handleStart(cw *connWriter, req Request) {
-	args := strings.Fields(req.CLIType)
+	s.mu.RLock()
+	_, running := s.sessions[req.SessionID]
+	s.mu.RUnlock()
+	if running {
+		s.sendResponse(cw, Response{ID: req.ID, Event: evtError, Error: "session already running"})
+		return
+	}
+
+	args := req.Args
+	if len(args) == 0 {
+		args = strings.Fields(req.CLIType)
+	}
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Dir = req.WorkDir
-	cmd.Env = os.Environ()
+	cmd.Env = append(os.Environ(), req.Env...)
 
-	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: 40, Cols: 120})
+	ptmx, err := ptymgr.StartCommand(cmd, req.Stdin)
 	if err != nil {
 		s.sendResponse(cw, Response{ID: req.ID, Event: evtError, Error: err.Error()})
 		return
@@ -274,8 +328,18 @@ func (s *Shepherd) handleStart(cw *connWriter, req Request) {
 		done:        make(chan struct{}),
 		subscribers: make(map[chan []byte]struct{}),
 	}
+	if tw, err := transcript.NewWriter(req.SessionID); err != nil {
+		log.Printf("shepherd: transcript disabled for %s: %v", req.SessionID, err)
+	} else {
+		sess.transcript = tw
+	}
+	if rec, err := transcript.NewRecorder(req.SessionID, 120, 40); err != nil {
+		log.Printf("shepherd: recording disabled for %s: %v", req.SessionID, err)
+	} else {
+		sess.recorder = rec
+	}
 
-	// Read PTY output → replay buffer + subscribers
+	// Read PTY output → replay buffer + transcript + subscribers
 	go func() {
 		buf := make([]byte, 32*1024)
 		for {
@@ -284,12 +348,24 @@ func (s *Shepherd) handleStart(cw *connWriter, req Request) {
 				data := make([]byte, n)
 				copy(data, buf[:n])
 				sess.appendReplay(data)
+				if sess.transcript != nil {
+					sess.transcript.Write(data)
+				}
+				if sess.recorder != nil {
+					sess.recorder.Output(data)
+				}
 				sess.broadcast(data)
 			}
 			if err != nil {
 				break
 			}
 		}
+		if sess.transcript != nil {
+			sess.transcript.Close()
+		}
+		if sess.recorder != nil {
+			sess.recorder.Close()
+		}
 		sess.subMu.Lock()
 		for ch := range sess.subscribers {
 			close(ch)
@@ -298,27 +374,27 @@ func (s *Shepherd) handleStart(cw *connWriter, req Request) {
 		sess.subMu.Unlock()
 	}()
 
-	// Monitor process exit
+	// Register before the exit monitor starts so it always finds sess.
+	s.mu.Lock()
+	s.sessions[req.SessionID] = sess
+	s.mu.Unlock()
+
+	// Monitor process exit. This is the only place sessions leave the map.
+	// Stops wait on done, so by then the ID is free and every client has
+	// seen the exit.
 	go func() {
 		cmd.Wait()
 		sess.mu.Lock()
 		sess.stopped = true
 		sess.mu.Unlock()
-		close(sess.done)
-
-		// Notify all connected clients
-		s.broadcastExit(req.SessionID)
 
-		// Remove from sessions map
 		s.mu.Lock()
 		delete(s.sessions, req.SessionID)
 		s.mu.Unlock()
+		s.broadcastExit(req.SessionID)
+		close(sess.done)
 	}()
 
-	s.mu.Lock()
-	s.sessions[req.SessionID] = sess
-	s.mu.Unlock()
-
 	s.sendResponse(cw, Response{
 		ID:        req.ID,
 		Event:     evtStarted,
@@ -328,26 +404,41 @@ func (s *Shepherd) handleStart(cw *connWriter, req Request) {
 }
 
 func (s *Shepherd) handleStop(cw *connWriter, req Request) {
-	s.mu.Lock()
+	s.mu.RLock()
 	sess, ok := s.sessions[req.SessionID]
-	if !ok {
-		s.mu.Unlock()
-		s.sendResponse(cw, Response{ID: req.ID, Event: evtStopDone})
-		return
+	s.mu.RUnlock()
+	if ok {
+		sess.stop()
+		sess.wait()
 	}
-	delete(s.sessions, req.SessionID)
-	s.mu.Unlock()
+	s.sendResponse(cw, Response{ID: req.ID, Event: evtStopDone})
+}
 
+// stop signals the session's process to exit.
+func (sess *session) stop() {
 	sess.mu.Lock()
-	if !sess.stopped {
-		if sess.cmd.Process != nil {
-			sess.cmd.Process.Signal(syscall.SIGTERM)
-		}
-		sess.ptmx.Close()
+	defer sess.mu.Unlock()
+	if sess.stopped {
+		return
+	}
+	if sess.cmd.Process != nil {
+		sess.cmd.Process.Signal(syscall.SIGTERM)
 	}
-	sess.mu.Unlock()
+	sess.ptmx.Close()
+}
 
-	s.sendResponse(cw, Response{ID: req.ID, Event: evtStopDone})
+// wait blocks until the exit monitor has removed the session, killing the
+// process if it outlives stopGrace.
+func (sess *session) wait() {
+	select {
+	case <-sess.done:
+		return
+	case <-time.After(stopGrace):
+	}
+	if sess.cmd.Process != nil {
+		sess.cmd.Process.Kill()
+	}
+	<-sess.done
 }
 
 func (s *Shepherd) handleResize(cw *connWriter, req Request) {
@@ -362,6 +453,9 @@ func (s *Shepherd) handleResize(cw *connWriter, req Request) {
 		s.sendResponse(cw, Response{ID: req.ID, Event: evtError, Error: err.Error()})
 		return
 	}
+	if sess.recorder != nil {
+		sess.recorder.Resize(req.Cols, req.Rows)
+	}
 	s.sendResponse(cw, Response{ID: req.ID, Event: "resized"})
 }
 
@@ -445,23 +539,18 @@ func (s *Shepherd) broadcastExit(sessionID string) {
 }
 
 func (s *Shepherd) stopAll() {
-	s.mu.Lock()
+	s.mu.RLock()
 	sessions := make([]*session, 0, len(s.sessions))
 	for _, sess := range s.sessions {
 		sessions = append(sessions, sess)
 	}
-	s.sessions = make(map[string]*session)
-	s.mu.Unlock()
+	s.mu.RUnlock()
 
 	for _, sess := range sessions {
-		sess.mu.Lock()
-		if !sess.stopped {
-			if sess.cmd.Process != nil {
-				sess.cmd.Process.Signal(syscall.SIGTERM)
-			}
-			sess.ptmx.Close()
-		}
-		sess.mu.Unlock()
+		sess.stop()
+	}
+	for _, sess := range sessions {
+		sess.wait()
 	}
 }
 
@@ -0,0 +1,172 @@
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxRecordingSize stops a recording from growing without bound. Unlike the
+// transcript it can't be rotated, since playback needs the whole file.
+const maxRecordingSize = 256 * 1024 * 1024
+
+const recordingName = "recording.cast"
+
+// RecordingPath returns the path of a session's asciicast v2 recording.
+func RecordingPath(sessionID string) (string, error) {
+	dir, err := SessionDir(sessionID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, recordingName), nil
+}
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Recorder writes PTY output and resize events as an asciicast v2 recording
+// (https://docs.asciinema.org/manual/asciicast/v2/).
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	start   time.Time
+	size    int64
+	pending []byte // trailing bytes of an incomplete UTF-8 sequence
+	full    bool
+}
+
+// NewRecorder opens a session's recording. A new file starts with a header
+// for a cols x rows terminal. If the session was recorded before (e.g. its
+// agent was relaunched) events are appended with times relative to the
+// original start, so the gap shows up as idle time on playback.
+func NewRecorder(sessionID string, cols, rows uint16) (*Recorder, error) {
+	path, err := RecordingPath(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("create recording dir: %w", err)
+	}
+
+	r := &Recorder{}
+	if f, err := os.Open(path); err == nil {
+		var hdr castHeader
+		line, _ := bufio.NewReader(f).ReadBytes('\n')
+		info, _ := f.Stat()
+		f.Close()
+		if json.Unmarshal(line, &hdr) == nil && hdr.Version == 2 && info != nil {
+			r.start = time.Unix(hdr.Timestamp, 0)
+			r.size = info.Size()
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open recording: %w", err)
+	}
+	r.file = f
+
+	if r.start.IsZero() {
+		r.start = time.Now()
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("reset recording: %w", err)
+		}
+		hdr := castHeader{
+			Version:   2,
+			Width:     cols,
+			Height:    rows,
+			Timestamp: r.start.Unix(),
+			Env:       map[string]string{"TERM": "xterm-256color"},
+		}
+		if err := r.writeLine(hdr); err != nil {
+			f.Close()
+			return nil, err
+		}
+	} else {
+		r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+	}
+	return r, nil
+}
+
+// Output records a chunk of PTY output. Multi-byte characters split across
+// chunks are held back until complete, since asciicast events are strings.
+func (r *Recorder) Output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.pending, p...)
+	cut := len(data)
+	// Walk back over at most 3 bytes looking for an incomplete rune start.
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		r.writeEvent("o", string(data[:cut]))
+	}
+}
+
+// Resize records a terminal resize.
+func (r *Recorder) Resize(cols, rows uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close flushes any held-back bytes and closes the recording.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	if len(r.pending) > 0 {
+		r.writeEvent("o", string(r.pending))
+		r.pending = nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// writeEvent appends a [time, code, data] event. Callers hold r.mu.
+func (r *Recorder) writeEvent(code, data string) {
+	if r.file == nil || r.full {
+		return
+	}
+	elapsed := math.Round(time.Since(r.start).Seconds()*1e6) / 1e6
+	r.writeLine([]any{elapsed, code, data})
+}
+
+func (r *Recorder) writeLine(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode recording event: %w", err)
+	}
+	line = append(line, '\n')
+	if r.size+int64(len(line)) > maxRecordingSize {
+		r.full = true
+		return fmt.Errorf("recording size limit reached")
+	}
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	return err
+}
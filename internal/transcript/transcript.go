@@ -0,0 +1,294 @@
+package transcript
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/peterje/superposition/internal/db"
+)
+
+const (
+	segmentSize = 8 * 1024 * 1024 // rotate to a new file after 8MB
+	maxSegments = 16              // keep at most 128MB per session
+)
+
+// Segment files are named by the absolute offset of their first byte, so
+// offsets stay stable when old segments are pruned.
+const segmentExt = ".log"
+
+// Dir returns the root directory holding all session transcripts.
+func Dir() (string, error) {
+	dataDir, err := db.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "transcripts"), nil
+}
+
+// SessionDir returns the transcript directory for a single session.
+func SessionDir(sessionID string) (string, error) {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || sessionID == "." || sessionID == ".." {
+		return "", fmt.Errorf("invalid session id %q", sessionID)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, sessionID), nil
+}
+
+// Remove deletes a session's transcript.
+func Remove(sessionID string) error {
+	dir, err := SessionDir(sessionID)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+type segment struct {
+	path  string
+	start int64
+	size  int64
+}
+
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		start, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		segs = append(segs, segment{path: filepath.Join(dir, name), start: start, size: info.Size()})
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i].start < segs[j].start })
+	return segs, nil
+}
+
+func segmentName(start int64) string {
+	return fmt.Sprintf("%016d%s", start, segmentExt)
+}
+
+// Writer appends PTY output to a session's rotating transcript.
+type Writer struct {
+	dir string
+
+	mu     sync.Mutex
+	file   *os.File
+	start  int64 // absolute offset of the current segment
+	offset int64 // absolute offset of the next byte
+}
+
+// NewWriter opens a session's transcript for appending, continuing after any
+// output already recorded for the same session.
+func NewWriter(sessionID string) (*Writer, error) {
+	dir, err := SessionDir(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create transcript dir: %w", err)
+	}
+
+	w := &Writer{dir: dir}
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, fmt.Errorf("list transcript: %w", err)
+	}
+	if len(segs) > 0 {
+		last := segs[len(segs)-1]
+		w.start = last.start
+		w.offset = last.start + last.size
+	}
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) openSegment() error {
+	f, err := os.OpenFile(filepath.Join(w.dir, segmentName(w.start)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open transcript segment: %w", err)
+	}
+	w.file = f
+	return nil
+}
+
+// Write appends p, rotating to a new segment once the current one is full.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	n, err := w.file.Write(p)
+	w.offset += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	if w.offset-w.start >= segmentSize {
+		w.file.Close()
+		w.start = w.offset
+		if err := w.openSegment(); err != nil {
+			w.file = nil
+			return n, err
+		}
+		w.prune()
+	}
+	return n, nil
+}
+
+// prune removes the oldest segments beyond maxSegments.
+func (w *Writer) prune() {
+	segs, err := listSegments(w.dir)
+	if err != nil || len(segs) <= maxSegments {
+		return
+	}
+	for _, s := range segs[:len(segs)-maxSegments] {
+		os.Remove(s.path)
+	}
+}
+
+// Close flushes and closes the transcript.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// Reader is an io.ReadSeeker over the retained portion of a transcript.
+// Offsets are relative to Start, the first byte still on disk.
+type Reader struct {
+	segs []segment
+	size int64
+	pos  int64
+
+	cur    *os.File
+	curIdx int
+}
+
+// Open returns a reader over a session's transcript as it exists now.
+// Output written after Open is not visible to the returned reader.
+func Open(sessionID string) (*Reader, error) {
+	dir, err := SessionDir(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	segs, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segs) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	// The last segment may still be growing; pin its size now.
+	r := &Reader{segs: segs, curIdx: -1}
+	for _, s := range segs {
+		r.size += s.size
+	}
+	return r, nil
+}
+
+// Start returns the absolute offset of the first retained byte. It is
+// non-zero once old segments have been rotated away.
+func (r *Reader) Start() int64 {
+	return r.segs[0].start
+}
+
+// Size returns the number of retained bytes.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	// Locate the segment containing pos.
+	idx, segOff := 0, r.pos
+	for idx < len(r.segs) && segOff >= r.segs[idx].size {
+		segOff -= r.segs[idx].size
+		idx++
+	}
+	if idx >= len(r.segs) {
+		return 0, io.EOF
+	}
+
+	if r.curIdx != idx {
+		if r.cur != nil {
+			r.cur.Close()
+		}
+		f, err := os.Open(r.segs[idx].path)
+		if err != nil {
+			return 0, err
+		}
+		r.cur, r.curIdx = f, idx
+	}
+
+	remain := r.segs[idx].size - segOff
+	if int64(len(p)) > remain {
+		p = p[:remain]
+	}
+	n, err := r.cur.ReadAt(p, segOff)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.pos + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+	r.pos = abs
+	return abs, nil
+}
+
+// Close releases any open segment file.
+func (r *Reader) Close() error {
+	if r.cur != nil {
+		return r.cur.Close()
+	}
+	return nil
+}
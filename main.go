@@ -13,9 +13,13 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/peterje/superposition/internal/api"
 	"github.com/peterje/superposition/internal/db"
 	"github.com/peterje/superposition/internal/gateway"
 	gitops "github.com/peterje/superposition/internal/git"
@@ -31,6 +35,22 @@ import (
 var migrationsFS embed.FS
 
 func main() {
+	// When git runs us as GIT_ASKPASS, through the link named
+	// gitops.AskpassName, answer its prompt and exit.
+	if filepath.Base(os.Args[0]) == gitops.AskpassName {
+		if len(os.Args) != 2 {
+			fmt.Fprintf(os.Stderr, "usage: %s <prompt>\n", gitops.AskpassName)
+			os.Exit(2)
+		}
+		answer, err := gitops.Askpass(os.Args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "superposition askpass: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(answer)
+		return
+	}
+
 	// Subcommand dispatch
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -52,6 +72,7 @@ func main() {
 	gatewayURL := flag.String("gateway", envOrDefault("SP_GATEWAY_URL", ""), "gateway URL (e.g. wss://gateway.example.com/tunnel)")
 	gatewaySecret := flag.String("gateway-secret", envOrDefault("SP_GATEWAY_SECRET", ""), "gateway pre-shared secret")
 	flag.Parse()
+	api.SetLocalURL(fmt.Sprintf("http://127.0.0.1:%d", *port))
 
 	fmt.Println("Superposition - AI Coding Sessions")
 	fmt.Println("===================================")
@@ -65,38 +86,16 @@ func main() {
 	defer database.Close()
 
 	// Run migrations
-	migrationSQL, err := migrationsFS.ReadFile("migrations/001_initial.sql")
-	if err != nil {
-		log.Fatalf("Failed to read migrations: %v", err)
-	}
-	if err := db.Migrate(database, string(migrationSQL)); err != nil {
+	if err := db.Migrate(database, migrationsFS); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
-	migration002, err := migrationsFS.ReadFile("migrations/002_add_gemini.sql")
-	if err != nil {
-		log.Fatalf("Failed to read migration 002: %v", err)
-	}
-	if err := db.Migrate(database, string(migration002)); err != nil {
-		log.Fatalf("Failed to run migration 002: %v", err)
-	}
-	migration003, err := migrationsFS.ReadFile("migrations/003_add_local_repos.sql")
-	if err != nil {
-		log.Fatalf("Failed to read migration 003: %v", err)
-	}
-	if err := db.Migrate(database, string(migration003)); err != nil {
-		log.Fatalf("Failed to run migration 003: %v", err)
-	}
-	migration004, err := migrationsFS.ReadFile("migrations/004_add_session_base.sql")
-	if err != nil {
-		log.Fatalf("Failed to read migration 004: %v", err)
-	}
-	if err := db.Migrate(database, string(migration004)); err != nil {
-		log.Fatalf("Failed to run migration 004: %v", err)
-	}
+
+	// Remove tokens earlier versions stored in the repos' remote URLs
+	scrubRemoteCredentials(database)
 
 	// Preflight checks (after DB init so overrides can be read)
 	fmt.Println("Running preflight checks...")
-	cliStatus, gitOk := preflight.CheckAll(database)
+	clis, gitOk := preflight.CheckAll(database)
 	if !gitOk {
 		fmt.Println("\ngit is required. Please install git and try again.")
 		os.Exit(1)
@@ -117,7 +116,18 @@ func main() {
 	reconcileSessions(database, mgr, shepherdClient)
 
 	// Start server
-	srv := server.New(database, cliStatus, gitOk, web.SPAHandler(), mgr)
+	srv := server.New(database, gitOk, preflight.NewAgentCache(database, clis), web.SPAHandler(), mgr)
+
+	// Periodically fetch repositories in the background
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	go srv.Syncer.Run(syncCtx)
+
+	// Expire the worktrees of sessions stopped past the retention period
+	go func() {
+		for range time.Tick(time.Hour) {
+			cleanupWorktrees(database)
+		}
+	}()
 
 	addr := fmt.Sprintf("0.0.0.0:%d", *port)
 	httpSrv := &http.Server{
@@ -135,6 +145,8 @@ func main() {
 		// Do NOT stop PTY sessions — shepherd keeps them alive
 		// Do NOT clean up worktrees for running sessions
 
+		stopSync()
+
 		// Close shepherd client connection
 		if shepherdClient != nil {
 			shepherdClient.Close()
@@ -170,10 +182,24 @@ func connectOrStartShepherd() (*shepherd.Client, error) {
 	client, err := shepherd.NewClient(socketPath)
 	if err == nil {
 		if err := client.Ping(); err == nil {
-			log.Println("Connected to existing shepherd")
-			return client, nil
+			if !client.Outdated() {
+				log.Println("Connected to existing shepherd")
+				return client, nil
+			}
+			// A shepherd from before an upgrade can't pass every command
+			// intact. Replace it if that won't kill any sessions.
+			if ids, err := client.ListSessions(); err != nil || len(ids) > 0 {
+				log.Println("Connected to existing shepherd from an older version; restart it once its sessions are stopped")
+				return client, nil
+			}
+			log.Println("Restarting shepherd from an older version...")
+			client.Close()
+			if err := shepherd.Stop(); err != nil {
+				return nil, fmt.Errorf("stop old shepherd: %w", err)
+			}
+		} else {
+			client.Close()
 		}
-		client.Close()
 	}
 
 	// Launch a new shepherd process
@@ -255,7 +281,7 @@ func reconcileSessions(database *sql.DB, mgr ptymgr.SessionManager, client *shep
 
 	// Mark orphaned sessions as stopped
 	for _, id := range orphanIDs {
-		database.Exec(`UPDATE sessions SET status = 'stopped' WHERE id = ?`, id)
+		database.Exec(`UPDATE sessions SET status = 'stopped', stopped_at = CURRENT_TIMESTAMP WHERE id = ?`, id)
 	}
 	if len(orphanIDs) > 0 {
 		log.Printf("Marked %d orphaned sessions as stopped", len(orphanIDs))
@@ -269,7 +295,7 @@ func reconcileSessions(database *sql.DB, mgr ptymgr.SessionManager, client *shep
 		// Monitor for exit
 		go func() {
 			<-client.Done(sessionID)
-			database.Exec(`UPDATE sessions SET status = 'stopped' WHERE id = ?`, sessionID)
+			database.Exec(`UPDATE sessions SET status = 'stopped', stopped_at = CURRENT_TIMESTAMP WHERE id = ?`, sessionID)
 			log.Printf("Session %s stopped (detected via shepherd)", sessionID)
 		}()
 	}
@@ -282,7 +308,7 @@ func reconcileSessions(database *sql.DB, mgr ptymgr.SessionManager, client *shep
 }
 
 func cleanupStaleSessions(database *sql.DB) {
-	result, err := database.Exec(`UPDATE sessions SET status = 'stopped' WHERE status IN ('running', 'starting')`)
+	result, err := database.Exec(`UPDATE sessions SET status = 'stopped', stopped_at = CURRENT_TIMESTAMP WHERE status IN ('running', 'starting')`)
 	if err != nil {
 		log.Printf("Failed to clean up stale sessions: %v", err)
 		return
@@ -296,8 +322,21 @@ func cleanupStaleSessions(database *sql.DB) {
 	cleanupWorktrees(database)
 }
 
+// Stopped sessions keep their worktree for worktreeRetentionKey days, so they
+// can be restarted in place; 0 keeps worktrees forever.
+const (
+	worktreeRetentionKey     = "worktrees.retention_days"
+	defaultWorktreeRetention = 14 * 24 * time.Hour
+)
+
+// cleanupWorktrees removes the worktrees of stopped sessions that aren't
+// resumable or stopped longer ago than the retention period. Their branches
+// are kept, so restarting such a session checks its worktree out again,
+// without any changes that weren't committed.
 func cleanupWorktrees(database *sql.DB) {
-	rows, err := database.Query(`SELECT s.worktree_path, r.local_path FROM sessions s JOIN repositories r ON s.repo_id = r.id WHERE s.status = 'stopped' AND s.worktree_path != ''`)
+	retention := worktreeRetention(database)
+	rows, err := database.Query(`SELECT s.worktree_path, s.resumable, s.stopped_at, s.created_at, r.local_path
+		FROM sessions s JOIN repositories r ON s.repo_id = r.id WHERE s.status = 'stopped' AND s.worktree_path != ''`)
 	if err != nil {
 		return
 	}
@@ -305,17 +344,68 @@ func cleanupWorktrees(database *sql.DB) {
 
 	for rows.Next() {
 		var wtPath, repoPath string
-		if err := rows.Scan(&wtPath, &repoPath); err != nil {
+		var resumable bool
+		var stoppedAt, createdAt sql.NullTime
+		if err := rows.Scan(&wtPath, &resumable, &stoppedAt, &createdAt, &repoPath); err != nil {
 			continue
 		}
+		if resumable {
+			// Sessions stopped before stopped_at was recorded count from
+			// their creation.
+			stopped := stoppedAt.Time
+			if !stoppedAt.Valid {
+				stopped = createdAt.Time
+			}
+			if retention == 0 || time.Since(stopped) < retention {
+				continue
+			}
+		}
 		if _, err := os.Stat(wtPath); err == nil {
 			if err := gitops.RemoveWorktree(repoPath, wtPath); err != nil {
 				log.Printf("Failed to remove worktree %s: %v", wtPath, err)
+			} else {
+				log.Printf("Removed worktree %s of stopped session", wtPath)
 			}
 		}
 	}
 }
 
+// worktreeRetention returns how long stopped sessions keep their worktree.
+// Zero means forever.
+func worktreeRetention(database *sql.DB) time.Duration {
+	var val string
+	if err := database.QueryRow(`SELECT value FROM settings WHERE key = ?`, worktreeRetentionKey).Scan(&val); err != nil {
+		return defaultWorktreeRetention
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n < 0 {
+		return defaultWorktreeRetention
+	}
+	return time.Duration(n) * 24 * time.Hour
+}
+
+// scrubRemoteCredentials removes credentials embedded in the origin URL of
+// every cloned repository; they are now supplied at runtime instead.
+func scrubRemoteCredentials(database *sql.DB) {
+	rows, err := database.Query(`SELECT local_path FROM repositories WHERE repo_type != 'local' AND local_path != ''`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var repoPath string
+		if err := rows.Scan(&repoPath); err != nil {
+			continue
+		}
+		if changed, err := gitops.ScrubRemoteURL(repoPath); err != nil {
+			log.Printf("Failed to scrub remote URL of %s: %v", repoPath, err)
+		} else if changed {
+			log.Printf("Removed stored credentials from remote URL of %s", repoPath)
+		}
+	}
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -356,6 +446,13 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Implement http.Flusher so server-sent events are not buffered.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // Implement http.Hijacker so WebSocket upgrades work through the middleware.
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hj, ok := rw.ResponseWriter.(http.Hijacker); ok {
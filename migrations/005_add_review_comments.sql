@@ -0,0 +1,18 @@
+-- Persist diff review comments so they survive reloads and restarts.
+-- Comments are anchored to a file path, diff side and line number.
+-- line_content snapshots the anchored line so we can tell when a later
+-- diff has changed it and the comment is outdated.
+CREATE TABLE IF NOT EXISTS review_comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    path TEXT NOT NULL,
+    side TEXT NOT NULL CHECK(side IN ('old', 'new')),
+    line INTEGER NOT NULL,
+    line_content TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    outdated INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_review_comments_session ON review_comments(session_id);
@@ -0,0 +1,19 @@
+-- Drop the CHECK constraint on sessions.cli_type. Valid agents now come from
+-- the agent registry, which can be extended without a schema change.
+CREATE TABLE sessions_new (
+    id TEXT PRIMARY KEY,
+    repo_id INTEGER NOT NULL REFERENCES repositories(id),
+    worktree_path TEXT NOT NULL,
+    branch TEXT NOT NULL,
+    cli_type TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'starting',
+    pid INTEGER,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    source_branch TEXT NOT NULL DEFAULT '',
+    base_commit TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO sessions_new (id, repo_id, worktree_path, branch, cli_type, status, pid, created_at, source_branch, base_commit)
+    SELECT id, repo_id, worktree_path, branch, cli_type, status, pid, created_at, source_branch, base_commit FROM sessions;
+DROP TABLE sessions;
+ALTER TABLE sessions_new RENAME TO sessions;
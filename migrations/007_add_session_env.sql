@@ -0,0 +1,3 @@
+-- Store the per-session environment passed to POST /api/sessions so it can
+-- be reapplied if the session's agent is relaunched. JSON object of name -> value.
+ALTER TABLE sessions ADD COLUMN env TEXT NOT NULL DEFAULT '{}';
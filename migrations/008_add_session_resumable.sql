@@ -0,0 +1,4 @@
+-- Sessions are resumable by default: their worktree is kept after the agent
+-- exits so POST /api/sessions/{id}/restart can relaunch it in place.
+-- Non-resumable sessions have their worktree cleaned up on the next boot.
+ALTER TABLE sessions ADD COLUMN resumable INTEGER NOT NULL DEFAULT 1;
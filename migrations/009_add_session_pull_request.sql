@@ -0,0 +1,3 @@
+-- Pull request opened from a session via POST /api/sessions/{id}/pull-request.
+ALTER TABLE sessions ADD COLUMN pr_number INTEGER;
+ALTER TABLE sessions ADD COLUMN pr_url TEXT;
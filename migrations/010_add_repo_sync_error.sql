@@ -0,0 +1,2 @@
+-- Error from the most recent failed background or manual sync; cleared on success.
+ALTER TABLE repositories ADD COLUMN last_sync_error TEXT NOT NULL DEFAULT '';
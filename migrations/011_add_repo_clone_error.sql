@@ -0,0 +1,2 @@
+-- Why the initial clone failed, shown when clone_status is 'error'.
+ALTER TABLE repositories ADD COLUMN clone_error TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- Git host a remote repository belongs to (configured by host.<hostname>
+-- settings) and the URL it is cloned from. Existing remote repos are on github.com.
+ALTER TABLE repositories ADD COLUMN host TEXT NOT NULL DEFAULT '';
+ALTER TABLE repositories ADD COLUMN clone_url TEXT NOT NULL DEFAULT '';
+UPDATE repositories SET host = 'github.com', clone_url = 'https://github.com/' || owner || '/' || name || '.git'
+    WHERE repo_type = 'github';
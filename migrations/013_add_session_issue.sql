@@ -0,0 +1,3 @@
+-- GitHub issue a session was started from via POST /api/sessions {"issue": n}.
+ALTER TABLE sessions ADD COLUMN issue_number INTEGER;
+ALTER TABLE sessions ADD COLUMN issue_url TEXT;
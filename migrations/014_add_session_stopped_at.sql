@@ -0,0 +1,3 @@
+-- When a session's agent last exited. Stopped sessions lose their worktree
+-- once this is older than the worktrees.retention_days setting.
+ALTER TABLE sessions ADD COLUMN stopped_at DATETIME;
@@ -5,6 +5,7 @@ import {
   type DiffFile,
   type DiffHunk,
   type DiffLine,
+  type ReviewComment as StoredComment,
 } from "../lib/api";
 import { extToLang, tokenizeLines } from "../lib/highlighter";
 import { useToast } from "./Toast";
@@ -16,11 +17,16 @@ interface TokenSpan {
   color?: string;
 }
 
+type Side = "old" | "new";
+
 interface ReviewComment {
+  id?: number; // unset until saved to the server
   filePath: string;
+  side: Side;
   lineNum: number;
   lineContent: string;
   body: string;
+  outdated?: boolean;
 }
 
 interface ReviewCallbacks {
@@ -29,12 +35,14 @@ interface ReviewCallbacks {
   onOpenForm: (
     key: string,
     filePath: string,
+    side: Side,
     lineNum: number,
     lineContent: string,
   ) => void;
   onSaveComment: (key: string, body: string) => void;
   onDeleteComment: (key: string) => void;
   onCancelForm: () => void;
+  onRevert: (filePath: string, hunk?: number) => void;
 }
 
 export default function DiffViewer({
@@ -77,11 +85,22 @@ export default function DiffViewer({
   const fetchDiff = useCallback(async () => {
     setLoading(true);
     setError(null);
-    setComments(new Map());
     setActiveForm(null);
     try {
-      const data = await api.getSessionDiff(sessionId);
+      const [data, stored] = await Promise.all([
+        api.getSessionDiff(sessionId),
+        api
+          .getSessionComments(sessionId)
+          .catch(() => [] as StoredComment[]),
+      ]);
       setDiff(data);
+      setComments(
+        new Map(
+          stored.map(
+            (c) => [commentKey(c.path, c.side, c.line), fromStored(c)] as const,
+          ),
+        ),
+      );
 
       // If auto-submit is enabled and we have changes, submit immediately
       if (autoSubmit && data.files?.length > 0) {
@@ -109,6 +128,7 @@ export default function DiffViewer({
   const handleOpenForm = (
     key: string,
     filePath: string,
+    side: Side,
     lineNum: number,
     lineContent: string,
   ) => {
@@ -120,31 +140,47 @@ export default function DiffViewer({
     // Pre-populate the comment metadata (body will be filled on save)
     setComments((prev) => {
       const next = new Map(prev);
-      next.set(key, { filePath, lineNum, lineContent, body: "" });
+      next.set(key, { filePath, side, lineNum, lineContent, body: "" });
       return next;
     });
     setActiveForm(key);
   };
 
-  const handleSaveComment = (key: string, body: string) => {
-    setComments((prev) => {
-      const next = new Map(prev);
-      const existing = next.get(key);
-      if (existing) {
-        next.set(key, { ...existing, body });
-      }
-      return next;
-    });
-    setActiveForm(null);
+  const handleSaveComment = async (key: string, body: string) => {
+    const existing = comments.get(key);
+    if (!existing) return;
+    try {
+      const saved =
+        existing.id === undefined
+          ? await api.createSessionComment(sessionId, {
+              path: existing.filePath,
+              side: existing.side,
+              line: existing.lineNum,
+              body,
+            })
+          : await api.updateSessionComment(sessionId, existing.id, body);
+      setComments((prev) => new Map(prev).set(key, fromStored(saved)));
+      setActiveForm(null);
+    } catch (e: any) {
+      toast(e.message || "Failed to save comment", "error");
+    }
   };
 
-  const handleDeleteComment = (key: string) => {
-    setComments((prev) => {
-      const next = new Map(prev);
-      next.delete(key);
-      return next;
-    });
-    if (activeForm === key) setActiveForm(null);
+  const handleDeleteComment = async (key: string) => {
+    const existing = comments.get(key);
+    try {
+      if (existing?.id !== undefined) {
+        await api.deleteSessionComment(sessionId, existing.id);
+      }
+      setComments((prev) => {
+        const next = new Map(prev);
+        next.delete(key);
+        return next;
+      });
+      if (activeForm === key) setActiveForm(null);
+    } catch (e: any) {
+      toast(e.message || "Failed to delete comment", "error");
+    }
   };
 
   const handleCancelForm = () => {
@@ -186,7 +222,7 @@ export default function DiffViewer({
       message += `\n## ${filePath}\n`;
       for (const c of group) {
         const trimmed = c.lineContent.trim();
-        message += `\n**Line ${c.lineNum}**${trimmed ? ` (\`${trimmed}\`)` : ""}:\n> ${c.body}\n`;
+        message += `\n**Line ${c.lineNum}**${c.outdated ? " (outdated)" : ""}${trimmed ? ` (\`${trimmed}\`)` : ""}:\n> ${c.body}\n`;
       }
     }
     message += "\nPlease address these review comments.\n";
@@ -194,6 +230,13 @@ export default function DiffViewer({
     setSubmitting(true);
     try {
       await api.sendSessionInput(sessionId, message);
+      // Submitted comments have been handed to the agent; clear them so
+      // they aren't sent again.
+      await Promise.all(
+        validComments
+          .filter((c) => c.id !== undefined)
+          .map((c) => api.deleteSessionComment(sessionId, c.id!)),
+      );
       setComments(new Map());
       setActiveForm(null);
       toast("Review submitted", "success");
@@ -204,6 +247,18 @@ export default function DiffViewer({
     }
   };
 
+  const handleRevert = async (filePath: string, hunk?: number) => {
+    const what = hunk === undefined ? filePath : `this hunk of ${filePath}`;
+    if (!window.confirm(`Discard changes to ${what}?`)) return;
+    try {
+      await api.revertSessionDiff(sessionId, filePath, hunk);
+      toast("Changes discarded", "success");
+      fetchDiff();
+    } catch (e: any) {
+      toast(e.message || "Failed to discard changes", "error");
+    }
+  };
+
   const reviewCallbacks: ReviewCallbacks = {
     comments,
     activeForm,
@@ -211,6 +266,7 @@ export default function DiffViewer({
     onSaveComment: handleSaveComment,
     onDeleteComment: handleDeleteComment,
     onCancelForm: handleCancelForm,
+    onRevert: handleRevert,
   };
 
   // Count comments with actual bodies
@@ -386,6 +442,7 @@ export default function DiffViewer({
         {diff.files.map((file) => (
           <FileSection
             key={file.path}
+            sessionId={sessionId}
             file={file}
             viewMode={viewMode}
             isCollapsed={collapsed[file.path] ?? false}
@@ -419,41 +476,69 @@ function statusBadge(status: string) {
 }
 
 function FileSection({
-  file,
+  sessionId,
+  file: summary,
   viewMode,
   isCollapsed,
   onToggle,
   review,
 }: {
+  sessionId: string;
   file: DiffFile;
   viewMode: ViewMode;
   isCollapsed: boolean;
   onToggle: () => void;
   review: ReviewCallbacks;
 }) {
+  // Truncated files (generated or over the size caps) are fetched on demand.
+  const [loaded, setLoaded] = useState<DiffFile | null>(null);
+  const [loadingFile, setLoadingFile] = useState(false);
+  const file = loaded ?? summary;
+
+  const loadFile = async () => {
+    setLoadingFile(true);
+    try {
+      setLoaded(await api.getSessionDiffFile(sessionId, summary.path));
+    } catch (e) {
+      console.error(e);
+    } finally {
+      setLoadingFile(false);
+    }
+  };
+
   return (
     <div className="border-b border-zinc-800">
       {/* File header */}
-      <button
-        onClick={onToggle}
-        className="w-full flex items-center gap-2 px-4 py-2 text-sm hover:bg-zinc-800/50 transition-colors"
-      >
-        <span className="text-zinc-500 text-xs">{isCollapsed ? "+" : "-"}</span>
-        {statusBadge(file.status)}
-        <span className="text-zinc-200 font-mono text-xs truncate">
-          {file.old_path && file.old_path !== file.path
-            ? `${file.old_path} → ${file.path}`
-            : file.path}
-        </span>
-        <span className="ml-auto flex items-center gap-2 shrink-0 text-xs">
-          {file.additions > 0 && (
-            <span className="text-emerald-400">+{file.additions}</span>
-          )}
-          {file.deletions > 0 && (
-            <span className="text-red-400">-{file.deletions}</span>
-          )}
-        </span>
-      </button>
+      <div className="flex items-center hover:bg-zinc-800/50 transition-colors">
+        <button
+          onClick={onToggle}
+          className="flex-1 min-w-0 flex items-center gap-2 px-4 py-2 text-sm"
+        >
+          <span className="text-zinc-500 text-xs">
+            {isCollapsed ? "+" : "-"}
+          </span>
+          {statusBadge(file.status)}
+          <span className="text-zinc-200 font-mono text-xs truncate">
+            {file.old_path && file.old_path !== file.path
+              ? `${file.old_path} → ${file.path}`
+              : file.path}
+          </span>
+          <span className="ml-auto flex items-center gap-2 shrink-0 text-xs">
+            {file.additions > 0 && (
+              <span className="text-emerald-400">+{file.additions}</span>
+            )}
+            {file.deletions > 0 && (
+              <span className="text-red-400">-{file.deletions}</span>
+            )}
+          </span>
+        </button>
+        <button
+          onClick={() => review.onRevert(file.path)}
+          className="shrink-0 text-xs text-zinc-500 hover:text-red-400 px-3 py-2"
+        >
+          Discard
+        </button>
+      </div>
 
       {/* File content */}
       {!isCollapsed && (
@@ -462,6 +547,19 @@ function FileSection({
             <div className="px-4 py-3 text-xs text-zinc-500">
               Binary file changed
             </div>
+          ) : file.truncated ? (
+            <div className="flex items-center gap-3 px-4 py-3 text-xs text-zinc-500">
+              {file.generated
+                ? "Generated file not shown."
+                : "Large diff not shown."}
+              <button
+                onClick={loadFile}
+                disabled={loadingFile}
+                className="text-blue-400 hover:text-blue-300 disabled:opacity-50"
+              >
+                {loadingFile ? "Loading..." : "Load diff"}
+              </button>
+            </div>
           ) : viewMode === "unified" ? (
             <UnifiedView file={file} review={review} />
           ) : (
@@ -553,9 +651,12 @@ function useHighlightedLines(file: DiffFile) {
   return tokenMap;
 }
 
-function renderTokens(tokens: TokenSpan[] | undefined, content: string) {
+function renderTokens(tokens: TokenSpan[] | undefined, line: DiffLine) {
+  if (line.changes?.length) {
+    return renderChanges(tokens ?? [{ content: line.content }], line);
+  }
   if (!tokens) {
-    return <span>{content}</span>;
+    return <span>{line.content}</span>;
   }
   return (
     <>
@@ -568,11 +669,61 @@ function renderTokens(tokens: TokenSpan[] | undefined, content: string) {
   );
 }
 
-/** Returns the comment key for a diff line: filePath:lineNum */
-function commentKey(filePath: string, line: DiffLine): string {
-  // Use new_num for add/context lines, old_num for delete-only lines
-  const num = line.type === "delete" ? line.old_num : line.new_num;
-  return `${filePath}:${num}`;
+/**
+ * Splits syntax tokens at the line's intra-line change boundaries (character
+ * offsets from the backend) and highlights the changed parts.
+ */
+function renderChanges(tokens: TokenSpan[], line: DiffLine) {
+  const changes = line.changes ?? [];
+  const changedClass =
+    line.type === "add" ? "bg-emerald-500/30" : "bg-red-500/30";
+  const isChanged = (pos: number) =>
+    changes.some((c) => pos >= c.start && pos < c.end);
+
+  const parts: { content: string; color?: string; changed: boolean }[] = [];
+  let pos = 0;
+  for (const t of tokens) {
+    for (const ch of Array.from(t.content)) {
+      const changed = isChanged(pos++);
+      const last = parts[parts.length - 1];
+      if (last && last.changed === changed && last.color === t.color) {
+        last.content += ch;
+      } else {
+        parts.push({ content: ch, color: t.color, changed });
+      }
+    }
+  }
+
+  return (
+    <>
+      {parts.map((p, j) => (
+        <span
+          key={j}
+          className={p.changed ? `${changedClass} rounded-sm` : undefined}
+          style={p.color ? { color: p.color } : undefined}
+        >
+          {p.content}
+        </span>
+      ))}
+    </>
+  );
+}
+
+/** Returns the comment key for a line: filePath:side:lineNum */
+function commentKey(filePath: string, side: Side, lineNum: number): string {
+  return `${filePath}:${side}:${lineNum}`;
+}
+
+function fromStored(c: StoredComment): ReviewComment {
+  return {
+    id: c.id,
+    filePath: c.path,
+    side: c.side,
+    lineNum: c.line,
+    lineContent: c.line_content,
+    body: c.body,
+    outdated: c.outdated,
+  };
 }
 
 function InlineCommentForm({
@@ -650,6 +801,7 @@ function SavedCommentRow({
   onEdit: (
     key: string,
     filePath: string,
+    side: Side,
     lineNum: number,
     lineContent: string,
   ) => void;
@@ -660,6 +812,14 @@ function SavedCommentRow({
     <tr key={`comment-${key}`}>
       <td colSpan={colSpan} className="px-4 py-2">
         <div className="border border-blue-800/50 rounded bg-blue-950/30 p-2">
+          {comment.outdated && (
+            <span
+              className="inline-block mb-1 text-[10px] px-1.5 rounded bg-amber-900/40 text-amber-300"
+              title="The line this comment was left on has changed"
+            >
+              Outdated
+            </span>
+          )}
           <p className="text-xs text-zinc-200 whitespace-pre-wrap">
             {comment.body}
           </p>
@@ -669,6 +829,7 @@ function SavedCommentRow({
                 onEdit(
                   key,
                   comment.filePath,
+                  comment.side,
                   comment.lineNum,
                   comment.lineContent,
                 )
@@ -734,6 +895,7 @@ function UnifiedView({
             filePath={file.path}
             tokenMap={tokenMap}
             startIdx={hunkStartIndices[hi]}
+            hunkIndex={hi}
             unified
             review={review}
           />
@@ -748,6 +910,7 @@ function HunkRows({
   filePath,
   tokenMap,
   startIdx,
+  hunkIndex,
   unified,
   review,
 }: {
@@ -755,6 +918,7 @@ function HunkRows({
   filePath: string;
   tokenMap: Map<string, TokenSpan[]>;
   startIdx: number;
+  hunkIndex: number;
   unified?: boolean;
   review: ReviewCallbacks;
 }) {
@@ -766,7 +930,15 @@ function HunkRows({
       <tr key={`hdr-${hunk.header}`} className="bg-zinc-800/30">
         <td className="w-10 text-right pr-2 text-zinc-600 select-none" />
         <td className="w-10 text-right pr-2 text-zinc-600 select-none" />
-        <td className="pl-4 py-0.5 text-zinc-500">{hunk.header}</td>
+        <td className="pl-4 py-0.5 text-zinc-500">
+          {hunk.header}
+          <button
+            onClick={() => review.onRevert(filePath, hunkIndex)}
+            className="ml-3 font-sans text-zinc-600 hover:text-red-400"
+          >
+            Discard hunk
+          </button>
+        </td>
       </tr>,
     );
   }
@@ -782,8 +954,10 @@ function HunkRows({
           : "";
 
     if (unified) {
-      const key = commentKey(filePath, line);
+      // Delete lines are anchored on the old side, the rest on the new side
+      const side: Side = line.type === "delete" ? "old" : "new";
       const lineNum = line.type === "delete" ? line.old_num : line.new_num;
+      const key = commentKey(filePath, side, lineNum ?? 0);
       const hasComment =
         review.comments.has(key) && review.comments.get(key)!.body;
       const isFormOpen = review.activeForm === key;
@@ -794,7 +968,7 @@ function HunkRows({
             {lineNum && (
               <AddCommentButton
                 onClick={() =>
-                  review.onOpenForm(key, filePath, lineNum, line.content)
+                  review.onOpenForm(key, filePath, side, lineNum, line.content)
                 }
               />
             )}
@@ -815,7 +989,7 @@ function HunkRows({
             >
               {line.type === "add" ? "+" : line.type === "delete" ? "-" : " "}
             </span>
-            {renderTokens(tokens, line.content)}
+            {renderTokens(tokens, line)}
           </td>
         </tr>,
       );
@@ -963,7 +1137,9 @@ function SplitView({
                 ? tokenMap.get(`${row.leftIdx}:${line.content}`)
                 : undefined;
 
-            const key = line?.old_num ? `${file.path}:${line.old_num}` : null;
+            const key = line?.old_num
+              ? commentKey(file.path, "old", line.old_num)
+              : null;
             const hasComment =
               key && review.comments.has(key) && review.comments.get(key)!.body;
             const isFormOpen = key && review.activeForm === key;
@@ -972,6 +1148,7 @@ function SplitView({
               <SplitLineRows
                 key={i}
                 line={line}
+                side="old"
                 lineNum={line?.old_num}
                 bgClass={bgClass}
                 tokens={tokens}
@@ -1004,7 +1181,9 @@ function SplitView({
                 ? tokenMap.get(`${row.rightIdx}:${line.content}`)
                 : undefined;
 
-            const key = line?.new_num ? `${file.path}:${line.new_num}` : null;
+            const key = line?.new_num
+              ? commentKey(file.path, "new", line.new_num)
+              : null;
             const hasComment =
               key && review.comments.has(key) && review.comments.get(key)!.body;
             const isFormOpen = key && review.activeForm === key;
@@ -1013,6 +1192,7 @@ function SplitView({
               <SplitLineRows
                 key={i}
                 line={line}
+                side="new"
                 lineNum={line?.new_num}
                 bgClass={bgClass}
                 tokens={tokens}
@@ -1032,6 +1212,7 @@ function SplitView({
 
 function SplitLineRows({
   line,
+  side,
   lineNum,
   bgClass,
   tokens,
@@ -1042,6 +1223,7 @@ function SplitLineRows({
   review,
 }: {
   line: DiffLine | null;
+  side: Side;
   lineNum: number | undefined;
   bgClass: string;
   tokens: TokenSpan[] | undefined;
@@ -1058,14 +1240,20 @@ function SplitLineRows({
           {key && lineNum && (
             <AddCommentButton
               onClick={() =>
-                review.onOpenForm(key, filePath, lineNum, line?.content || "")
+                review.onOpenForm(
+                  key,
+                  filePath,
+                  side,
+                  lineNum,
+                  line?.content || "",
+                )
               }
             />
           )}
           {lineNum || ""}
         </td>
         <td className="pl-2 py-0 whitespace-pre">
-          {line ? renderTokens(tokens, line.content) : ""}
+          {line ? renderTokens(tokens, line) : ""}
         </td>
       </tr>
       {isFormOpen && key && (
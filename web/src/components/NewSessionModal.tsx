@@ -1,5 +1,5 @@
 import { useEffect, useState } from "react";
-import { api } from "../lib/api";
+import { api, type GitRef } from "../lib/api";
 
 interface Repo {
   id: number;
@@ -20,10 +20,14 @@ export default function NewSessionModal({ open, onClose, onCreated }: Props) {
   const [repoId, setRepoId] = useState<number | null>(null);
   const [sourceBranch, setSourceBranch] = useState("");
   const [newBranch, setNewBranch] = useState("");
-  const [branches, setBranches] = useState<string[]>([]);
-  const [cliType, setCliType] = useState<"claude" | "codex" | "gemini">(
+  const [prompt, setPrompt] = useState("");
+  const [branches, setBranches] = useState<GitRef[]>([]);
+  const [agents, setAgents] = useState<string[]>([
     "claude",
-  );
+    "codex",
+    "gemini",
+  ]);
+  const [cliType, setCliType] = useState("claude");
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState("");
   const [cliOverrides, setCliOverrides] = useState<Record<string, string>>({});
@@ -39,30 +43,41 @@ export default function NewSessionModal({ open, onClose, onCreated }: Props) {
           setRepoId(ready[0].id);
         }
       });
-      // Load CLI command overrides
-      Promise.all(
-        (["claude", "codex", "gemini"] as const).map((type) =>
-          api
-            .getSetting(`cli_command.${type}`)
-            .then((s) => [type, s.value] as const)
-            .catch(() => [type, ""] as const),
-        ),
-      ).then((results) => {
-        const overrides: Record<string, string> = {};
-        for (const [type, value] of results) {
-          if (value) overrides[type] = value;
-        }
-        setCliOverrides(overrides);
-      });
+      // Load registered agents, then their CLI command overrides
+      api
+        .health()
+        .then((h) => {
+          const names: string[] = (h.clis || []).map((c: any) => c.name);
+          if (names.length > 0) setAgents(names);
+          return names.length > 0 ? names : agents;
+        })
+        .catch(() => agents)
+        .then((names) =>
+          Promise.all(
+            names.map((type) =>
+              api
+                .getSetting(`cli_command.${type}`)
+                .then((s) => [type, s.value] as const)
+                .catch(() => [type, ""] as const),
+            ),
+          ),
+        )
+        .then((results) => {
+          const overrides: Record<string, string> = {};
+          for (const [type, value] of results) {
+            if (value) overrides[type] = value;
+          }
+          setCliOverrides(overrides);
+        });
     }
   }, [open]);
 
   useEffect(() => {
     if (repoId) {
-      api.getRepoBranches(repoId).then((b) => {
-        setBranches(b);
-        const repo = repos.find((r) => r.id === repoId);
-        const defaultBranch = repo?.default_branch || b[0] || "main";
+      api.getRepoRefs(repoId).then((refs) => {
+        setBranches(refs.branches);
+        const defaultBranch =
+          refs.default_branch || refs.branches[0]?.name || "main";
         setSourceBranch(defaultBranch);
         setNewBranch("");
       });
@@ -81,7 +96,9 @@ export default function NewSessionModal({ open, onClose, onCreated }: Props) {
         sourceBranch,
         newBranch.trim(),
         cliType,
+        prompt.trim() || undefined,
       );
+      setPrompt("");
       onCreated(session);
       onClose();
     } catch (e: any) {
@@ -140,8 +157,11 @@ export default function NewSessionModal({ open, onClose, onCreated }: Props) {
               className="w-full px-3 py-2.5 bg-zinc-800 border border-zinc-700 rounded-md text-sm"
             >
               {branches.map((b) => (
-                <option key={b} value={b}>
-                  {b}
+                <option key={b.name} value={b.name}>
+                  {b.name}
+                  {b.ahead || b.behind
+                    ? ` (${b.ahead} ahead, ${b.behind} behind)`
+                    : ""}
                 </option>
               ))}
             </select>
@@ -163,10 +183,23 @@ export default function NewSessionModal({ open, onClose, onCreated }: Props) {
             />
           </div>
 
+          <div>
+            <label className="block text-sm font-medium mb-1">
+              Initial Prompt
+            </label>
+            <textarea
+              value={prompt}
+              onChange={(e) => setPrompt(e.target.value)}
+              rows={3}
+              placeholder="Optional: what the agent should start working on"
+              className="w-full px-3 py-2.5 bg-zinc-800 border border-zinc-700 rounded-md text-sm placeholder-zinc-600 focus:outline-none focus:ring-1 focus:ring-blue-500"
+            />
+          </div>
+
           <div>
             <label className="block text-sm font-medium mb-1">CLI</label>
             <div className="flex gap-2">
-              {(["claude", "codex", "gemini"] as const).map((type) => (
+              {agents.map((type) => (
                 <button
                   key={type}
                   onClick={() => setCliType(type)}
@@ -180,7 +213,9 @@ export default function NewSessionModal({ open, onClose, onCreated }: Props) {
                     ? "Claude Code"
                     : type === "codex"
                       ? "Codex"
-                      : "Gemini CLI"}
+                      : type === "gemini"
+                        ? "Gemini CLI"
+                        : type}
                 </button>
               ))}
             </div>
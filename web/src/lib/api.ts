@@ -36,12 +36,53 @@ async function request<T>(path: string, options?: RequestInit): Promise<T> {
   return data;
 }
 
+// Clone or sync progress streamed from /api/repos/{id}/progress
+export interface RepoProgress {
+  status: "cloning" | "syncing" | "ready" | "error";
+  phase?: string;
+  percent: number;
+  error?: string;
+}
+
+// Review comment from /api/sessions/{id}/comments (models.ReviewComment)
+export interface ReviewComment {
+  id: number;
+  session_id: string;
+  path: string;
+  side: "old" | "new";
+  line: number;
+  line_content: string;
+  body: string;
+  outdated: boolean;
+  created_at: string;
+  updated_at: string;
+}
+
+// Refs from /api/repos/{id}/refs (match internal/git/refs.go)
+export interface GitRef {
+  name: string;
+  sha: string;
+  author: string;
+  date: string;
+  ahead?: number;
+  behind?: number;
+}
+
+export interface RepoRefs {
+  default_branch: string;
+  branches: GitRef[];
+  remote_branches: GitRef[];
+  tags: GitRef[];
+}
+
 // Diff types (match Go JSON output from internal/git/diff.go)
 export interface DiffLine {
   type: "add" | "delete" | "context";
   content: string;
   old_num?: number;
   new_num?: number;
+  // Intra-line changed ranges, as character offsets into content
+  changes?: { start: number; end: number }[];
 }
 
 export interface DiffHunk {
@@ -61,8 +102,13 @@ export interface DiffFile {
   additions: number;
   deletions: number;
   hunks: DiffHunk[];
+  location?: "committed" | "staged" | "unstaged" | "untracked" | "mixed";
+  generated?: boolean;
+  truncated?: boolean;
 }
 
+export type DiffMode = "committed" | "staged" | "unstaged" | "all";
+
 export interface DiffStats {
   files_changed: number;
   additions: number;
@@ -74,6 +120,25 @@ export interface DiffResponse {
   stats: DiffStats;
 }
 
+export interface Commit {
+  sha: string;
+  author: string;
+  author_email: string;
+  subject: string;
+  message: string;
+  timestamp: string;
+  stats: DiffStats;
+}
+
+export interface FileLines {
+  path: string;
+  ref: "base" | "worktree";
+  start: number;
+  end: number;
+  total_lines: number;
+  lines: string[];
+}
+
 export const api = {
   // Health
   health: () => request<any>("/api/health"),
@@ -106,10 +171,10 @@ export const api = {
 
   // Repos
   getRepos: () => request<any[]>("/api/repos"),
-  addRepo: (githubUrl: string) =>
+  addRepo: (url: string) =>
     request<any>("/api/repos", {
       method: "POST",
-      body: JSON.stringify({ github_url: githubUrl }),
+      body: JSON.stringify({ url }),
     }),
   addLocalRepo: (path: string) =>
     request<any>("/api/repos", {
@@ -122,6 +187,18 @@ export const api = {
     request<any>(`/api/repos/${id}/sync`, { method: "POST" }),
   getRepoBranches: (id: number) =>
     request<string[]>(`/api/repos/${id}/branches`),
+  getRepoRefs: (id: number) => request<RepoRefs>(`/api/repos/${id}/refs`),
+  // Streams clone/sync progress; returns a function that stops watching.
+  watchRepoProgress: (id: number, onProgress: (p: RepoProgress) => void) => {
+    const source = new EventSource(`${BASE}/api/repos/${id}/progress`);
+    source.addEventListener("progress", (e) => {
+      const p: RepoProgress = JSON.parse((e as MessageEvent).data);
+      onProgress(p);
+      if (p.status === "ready" || p.status === "error") source.close();
+    });
+    source.onerror = () => source.close();
+    return () => source.close();
+  },
 
   // Sessions
   getSessions: () => request<any[]>("/api/sessions"),
@@ -130,6 +207,7 @@ export const api = {
     sourceBranch: string,
     newBranch: string,
     cliType: string,
+    prompt?: string,
   ) =>
     request<any>("/api/sessions", {
       method: "POST",
@@ -138,6 +216,7 @@ export const api = {
         source_branch: sourceBranch,
         new_branch: newBranch,
         cli_type: cliType,
+        prompt,
       }),
     }),
   deleteSession: (id: string, deleteLocal = true) =>
@@ -153,8 +232,82 @@ export const api = {
       if (!res.ok) throw new Error("Failed to fetch replay");
       return res.arrayBuffer();
     }),
-  getSessionDiff: (id: string) =>
-    request<DiffResponse>(`/api/sessions/${id}/diff`),
+  getSessionDiff: (id: string, mode: DiffMode = "all") =>
+    request<DiffResponse>(`/api/sessions/${id}/diff?mode=${mode}`),
+  getSessionDiffFile: (id: string, path: string, mode: DiffMode = "all") =>
+    request<DiffFile>(
+      `/api/sessions/${id}/diff/file?${new URLSearchParams({ path, mode })}`,
+    ),
+  revertSessionDiff: (id: string, path: string, hunk?: number) =>
+    request<void>(`/api/sessions/${id}/diff/revert`, {
+      method: "POST",
+      body: JSON.stringify({ path, hunk }),
+    }),
+  getSessionComments: (id: string) =>
+    request<ReviewComment[]>(`/api/sessions/${id}/comments`),
+  createSessionComment: (
+    id: string,
+    comment: { path: string; side: "old" | "new"; line: number; body: string },
+  ) =>
+    request<ReviewComment>(`/api/sessions/${id}/comments`, {
+      method: "POST",
+      body: JSON.stringify(comment),
+    }),
+  updateSessionComment: (id: string, commentId: number, body: string) =>
+    request<ReviewComment>(`/api/sessions/${id}/comments/${commentId}`, {
+      method: "PUT",
+      body: JSON.stringify({ body }),
+    }),
+  deleteSessionComment: (id: string, commentId: number) =>
+    request<void>(`/api/sessions/${id}/comments/${commentId}`, {
+      method: "DELETE",
+    }),
+  getSessionStatus: (id: string) =>
+    request<
+      { path: string; orig_path?: string; index: string; worktree: string }[]
+    >(`/api/sessions/${id}/status`),
+  commitSession: (id: string, message?: string, paths?: string[]) =>
+    request<{ sha: string; message: string }>(`/api/sessions/${id}/commit`, {
+      method: "POST",
+      body: JSON.stringify({ message, paths }),
+    }),
+  mergeSession: (
+    id: string,
+    strategy: "merge" | "squash" | "rebase",
+    message?: string,
+  ) =>
+    request<{ strategy: string; commit: string; source_branch: string }>(
+      `/api/sessions/${id}/merge`,
+      { method: "POST", body: JSON.stringify({ strategy, message }) },
+    ),
+  rebaseSession: (id: string) =>
+    request<{ base_commit: string }>(`/api/sessions/${id}/rebase`, {
+      method: "POST",
+    }),
+  getSessionCommits: (id: string) =>
+    request<Commit[]>(`/api/sessions/${id}/commits`),
+  getCommitDiff: (id: string, sha: string) =>
+    request<DiffResponse>(`/api/sessions/${id}/commits/${sha}/diff`),
+  getSessionFile: (
+    id: string,
+    path: string,
+    ref: "base" | "worktree",
+    start: number,
+    end: number,
+  ) => {
+    const params = new URLSearchParams({
+      path,
+      ref,
+      start: String(start),
+      end: String(end),
+    });
+    return request<FileLines>(`/api/sessions/${id}/file?${params}`);
+  },
+  createPullRequest: (id: string) =>
+    request<{ number: number; html_url: string }>(
+      `/api/sessions/${id}/pull-request`,
+      { method: "POST" },
+    ),
   sendSessionInput: (id: string, data: string) =>
     request<void>(`/api/sessions/${id}/input`, {
       method: "POST",
@@ -1,5 +1,9 @@
 import { useEffect, useState, useCallback, useRef } from "react";
-import { api, SuperpositionOfflineError } from "../lib/api";
+import {
+  api,
+  SuperpositionOfflineError,
+  type RepoProgress,
+} from "../lib/api";
 
 interface LocalRepo {
   id: number;
@@ -7,10 +11,15 @@ interface LocalRepo {
   owner: string;
   name: string;
   clone_status: string;
+  clone_error: string;
   default_branch: string;
   last_synced: string | null;
   repo_type: string;
+  host: string;
   source_path: string | null;
+  last_sync_error: string;
+  syncing: boolean;
+  next_sync: string | null;
 }
 
 interface GitHubRepo {
@@ -168,7 +177,9 @@ export default function Repositories() {
                   <p className="font-medium break-all">
                     {repo.repo_type === "local"
                       ? repo.name
-                      : `${repo.owner}/${repo.name}`}
+                      : repo.host && repo.host !== "github.com"
+                        ? `${repo.host}/${repo.owner}/${repo.name}`
+                        : `${repo.owner}/${repo.name}`}
                     {repo.repo_type === "local" && (
                       <span className="ml-2 text-xs text-zinc-500 border border-zinc-700 px-1.5 py-0.5 rounded">
                         local
@@ -185,10 +196,23 @@ export default function Repositories() {
                       ? `Ready — ${repo.default_branch}`
                       : repo.clone_status === "cloning"
                         ? "Cloning..."
-                        : `Error`}
-                    {repo.last_synced &&
-                      ` — synced ${new Date(repo.last_synced).toLocaleString()}`}
+                        : `Error${repo.clone_error ? `: ${repo.clone_error}` : ""}`}
+                    {repo.syncing
+                      ? " — syncing..."
+                      : repo.last_synced &&
+                        ` — synced ${new Date(repo.last_synced).toLocaleString()}`}
+                    {!repo.syncing &&
+                      repo.next_sync &&
+                      `, next ${new Date(repo.next_sync).toLocaleTimeString()}`}
                   </p>
+                  {(repo.clone_status === "cloning" || repo.syncing) && (
+                    <ProgressLine repoId={repo.id} />
+                  )}
+                  {repo.last_sync_error && (
+                    <p className="text-xs text-red-400 break-all">
+                      Sync failed: {repo.last_sync_error}
+                    </p>
+                  )}
                 </div>
                 <div className="flex flex-wrap items-center gap-2">
                   <StatusDot status={repo.clone_status} />
@@ -316,6 +340,27 @@ export default function Repositories() {
   );
 }
 
+function ProgressLine({ repoId }: { repoId: number }) {
+  const [progress, setProgress] = useState<RepoProgress | null>(null);
+
+  useEffect(() => api.watchRepoProgress(repoId, setProgress), [repoId]);
+
+  if (!progress?.phase) return null;
+  return (
+    <div className="mt-1 w-48">
+      <p className="text-xs text-zinc-500">
+        {progress.phase} {progress.percent}%
+      </p>
+      <div className="h-1 bg-zinc-800 rounded">
+        <div
+          className="h-1 bg-blue-500 rounded"
+          style={{ width: `${progress.percent}%` }}
+        />
+      </div>
+    </div>
+  );
+}
+
 function StatusDot({ status }: { status: string }) {
   const color =
     status === "ready"
@@ -18,8 +18,13 @@ interface SessionInfo {
   created_at: string;
   repo_owner: string;
   repo_name: string;
+  repo_type: string;
   source_branch: string;
   base_commit: string;
+  pr_number: number | null;
+  pr_url: string | null;
+  issue_number: number | null;
+  issue_url: string | null;
 }
 
 export default function Sessions() {
@@ -117,6 +122,18 @@ export default function Sessions() {
     load();
   };
 
+  const handlePullRequest = async (id: string) => {
+    try {
+      const pr = await api.createPullRequest(id);
+      window.open(pr.html_url, "_blank", "noopener");
+      load();
+    } catch (e) {
+      window.alert(
+        e instanceof Error ? e.message : "Failed to open pull request",
+      );
+    }
+  };
+
   const runningSessions = sessions.filter((s) => s.status === "running");
   const stoppedSessions = sessions.filter((s) => s.status !== "running");
 
@@ -292,6 +309,7 @@ export default function Sessions() {
                 session={s}
                 idle={idleSessions.has(s.id)}
                 onOpen={() => openTab(s.id)}
+                onPullRequest={() => handlePullRequest(s.id)}
                 onDelete={() => handleDelete(s.id)}
               />
             ))}
@@ -309,6 +327,7 @@ export default function Sessions() {
               <SessionCard
                 key={s.id}
                 session={s}
+                onPullRequest={() => handlePullRequest(s.id)}
                 onDelete={() => handleDelete(s.id)}
               />
             ))}
@@ -335,11 +354,13 @@ function SessionCard({
   session,
   idle,
   onOpen,
+  onPullRequest,
   onDelete,
 }: {
   session: SessionInfo;
   idle?: boolean;
   onOpen?: () => void;
+  onPullRequest: () => void;
   onDelete: () => void;
 }) {
   const running = session.status === "running";
@@ -381,6 +402,35 @@ function SessionCard({
             Open Terminal
           </button>
         )}
+        {session.issue_url && (
+          <a
+            href={session.issue_url}
+            target="_blank"
+            rel="noopener noreferrer"
+            className="text-xs bg-zinc-800 hover:bg-zinc-700 px-3 py-1.5 rounded transition-colors"
+          >
+            Issue #{session.issue_number}
+          </a>
+        )}
+        {session.pr_url && (
+          <a
+            href={session.pr_url}
+            target="_blank"
+            rel="noopener noreferrer"
+            className="text-xs bg-zinc-800 hover:bg-zinc-700 px-3 py-1.5 rounded transition-colors"
+          >
+            PR #{session.pr_number}
+          </a>
+        )}
+        {/* Pushing again opens a new PR if the last one was closed */}
+        {session.repo_type === "github" && (
+          <button
+            onClick={onPullRequest}
+            className="text-xs bg-zinc-800 hover:bg-zinc-700 px-3 py-1.5 rounded transition-colors"
+          >
+            {session.pr_url ? "Push to PR" : "Create PR"}
+          </button>
+        )}
         <button
           onClick={onDelete}
           className="text-xs text-zinc-400 hover:text-red-400 px-3 py-1.5 rounded border border-zinc-700 hover:border-red-800 transition-colors"